@@ -0,0 +1,688 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/swit33/go-tms/pkg/boot"
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/exporter"
+	"github.com/swit33/go-tms/pkg/importer"
+	"github.com/swit33/go-tms/pkg/plugin"
+	"github.com/swit33/go-tms/pkg/session"
+	"github.com/swit33/go-tms/pkg/tmux"
+)
+
+func runCommand(args []string, cfg *config.Config) error {
+	switch args[0] {
+	case "import":
+		return runImport(args[1:], cfg)
+	case "export":
+		return runExport(args[1:], cfg)
+	case "remap":
+		return runRemap(args[1:], cfg)
+	case "prune":
+		return runPrune(args[1:], cfg)
+	case "restore":
+		return runRestore(args[1:], cfg)
+	case "set":
+		return runSet(args[1:], cfg)
+	case "config":
+		return runConfig(args[1:], cfg)
+	case "plugins":
+		return runPlugins(args[1:], cfg)
+	case "template":
+		return runTemplate(args[1:], cfg)
+	case "last":
+		return runLast(args[1:], cfg)
+	case "slot":
+		return runSlot(args[1:], cfg)
+	}
+	if _, err := plugin.Find(args[0]); err == nil {
+		return plugin.Run(args[0], args[1:], pluginContext(nil))
+	}
+	return fmt.Errorf("unknown command: %s", args[0])
+}
+
+func runImport(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	all := fs.Bool("all", false, "Import every project file from the tmuxinator, tmuxp and smug directories")
+	force := fs.Bool("force", false, "Overwrite saved sessions with the same name")
+	name := fs.String("name", "", "Name of the imported session (single file only)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-tms import [-all] [-force] [-name NAME] [FILE...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	files := fs.Args()
+	if *all {
+		found, err := importer.FindFiles()
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return fmt.Errorf("no project files to import")
+	}
+	if *name != "" && len(files) != 1 {
+		return fmt.Errorf("-name can only be used when importing a single file")
+	}
+
+	sessions, err := session.LoadSessionsFromDisk()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		imported, err := importer.LoadFile(file)
+		if err != nil {
+			return err
+		}
+		if *name != "" {
+			imported.Name = session.SanitizeName(*name)
+		}
+		if session.CheckIfSessionExists(imported.Name, sessions) {
+			if !*force {
+				fmt.Fprintf(os.Stderr, "Skipping %s: session %s already exists (use -force to overwrite)\n", file, imported.Name)
+				continue
+			}
+			sessions, _ = session.DeleteSession(imported.Name, sessions)
+		}
+		sessions = append(sessions, imported)
+		fmt.Printf("Imported %s from %s\n", imported.Name, file)
+	}
+	return session.SaveSessionsToDisk(sessions)
+}
+
+func runExport(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", exporter.FormatScript,
+		"Output format: "+strings.Join(exporter.Formats, ", "))
+	output := fs.String("o", "", "Write to file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-tms export [-format FORMAT] [-o FILE] SESSION")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one session name")
+	}
+
+	sessions, err := loadAllSessions(cfg)
+	if err != nil {
+		return err
+	}
+	sessionInstance, err := session.GetSessionByName(fs.Arg(0), sessions)
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, fs.Arg(0))
+	}
+	data, err := exporter.Export(sessionInstance, *format, cfg)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	mode := os.FileMode(0644)
+	if *format == exporter.FormatScript {
+		mode = 0755
+	}
+	return os.WriteFile(*output, data, mode)
+}
+
+// loadAllSessions returns live tmux sessions followed by saved ones.
+func loadAllSessions(cfg *config.Config) ([]session.Session, error) {
+	sessions, err := session.LoadSessionsFromDisk()
+	if err != nil {
+		return nil, err
+	}
+	tmuxSessions, err := tmux.ListSessions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return session.CombineSessions(tmuxSessions, sessions)
+}
+
+func runRemap(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("remap", flag.ExitOnError)
+	dryRun := fs.Bool("n", false, "Show what would change without saving")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-tms remap [-n] FROM TO")
+		fmt.Fprintln(fs.Output(), "Rewrites every saved path below FROM to the same path below TO.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected FROM and TO prefixes")
+	}
+	from := session.ExpandPath(fs.Arg(0))
+	to := session.ExpandPath(fs.Arg(1))
+
+	sessions, err := session.LoadSessionsFromDisk()
+	if err != nil {
+		return err
+	}
+	remap := session.RemapPrefix(from, to)
+	changed := 0
+	for i, s := range sessions {
+		remapped := s.MapPaths(remap)
+		if reflect.DeepEqual(remapped, s) {
+			continue
+		}
+		changed++
+		fmt.Printf("%s: %s -> %s\n", s.Name, s.CurrentPath, remapped.CurrentPath)
+		sessions[i] = remapped
+	}
+	fmt.Printf("%d of %d sessions remapped\n", changed, len(sessions))
+	if *dryRun || changed == 0 {
+		return nil
+	}
+	return session.SaveSessionsToDisk(sessions)
+}
+
+func runPrune(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	dryRun := fs.Bool("n", false, "Show what would be removed without saving")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-tms prune [-n]")
+		fmt.Fprintln(fs.Output(), "Removes saved sessions whose directory no longer exists.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	sessions, err := session.LoadSessionsFromDisk()
+	if err != nil {
+		return err
+	}
+	kept, pruned := session.PruneStale(sessions)
+	for _, s := range pruned {
+		fmt.Printf("%s: %s\n", s.Name, s.CurrentPath)
+	}
+	fmt.Printf("%d of %d sessions pruned\n", len(pruned), len(sessions))
+	if *dryRun || len(pruned) == 0 {
+		return nil
+	}
+	return session.ReplaceSessionsOnDisk(kept)
+}
+
+func runRestore(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Print the tmux commands instead of running them")
+	all := fs.Bool("all", false, "Restore every saved session selected by restore-sessions without switching")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-tms restore [-dry-run] SESSION")
+		fmt.Fprintln(fs.Output(), "       go-tms restore -all [-dry-run]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *all {
+		if fs.NArg() != 0 {
+			fs.Usage()
+			return fmt.Errorf("-all does not take session names")
+		}
+		if *dryRun {
+			return printRestoreAllPlans(cfg)
+		}
+		return boot.RestoreAll(cfg, os.Stdout)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one session name")
+	}
+
+	sessions, err := loadAllSessions(cfg)
+	if err != nil {
+		return err
+	}
+	sessionInstance, err := session.GetSessionByName(fs.Arg(0), sessions)
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, fs.Arg(0))
+	}
+	if !*dryRun {
+		return handleSessionLogic(false, sessionInstance.Name, &sessions, cfg)
+	}
+	if sessionInstance.TmuxActive {
+		fmt.Printf("# %s is already running, it would be switched to\n", sessionInstance.Name)
+		fmt.Printf("tmux switch-client -t %s\n", exporter.Quote(sessionInstance.Name))
+		return nil
+	}
+	return printRestorePlan(sessionInstance, true, cfg)
+}
+
+// printRestoreAllPlans prints the plans of every session restore -all would
+// bring back.
+func printRestoreAllPlans(cfg *config.Config) error {
+	sessions, err := loadAllSessions(cfg)
+	if err != nil {
+		return err
+	}
+	for _, s := range boot.SelectSessions(sessions, cfg.RestoreSessions) {
+		if s.TmuxActive {
+			continue
+		}
+		fmt.Printf("# session %s\n", s.Name)
+		if err := printRestorePlan(&s, false, cfg); err != nil {
+			fmt.Printf("# %v\n", err)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// printRestorePlan prints the tmux invocations a restore of the session
+// would run, each batching several commands, and the programs it would
+// relaunch.
+func printRestorePlan(s *session.Session, switchClient bool, cfg *config.Config) error {
+	restorable, problems, err := tmux.PrepareRestore(s, cfg)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Printf("# %s\n", p)
+	}
+	for _, invocation := range tmux.RestoreInvocations(restorable, cfg) {
+		if invocation.WaitForShells {
+			fmt.Println("# once every pane shows a prompt:")
+		}
+		fmt.Println(tmuxCommandLine(invocation.Args))
+	}
+	fmt.Printf("# if any command fails: %s\n", tmuxCommandLine(tmux.RollbackArgs(restorable)))
+	if switchClient {
+		fmt.Printf("tmux switch-client -t %s\n", exporter.Quote(restorable.Name))
+	}
+
+	var relaunched []string
+	for i, w := range restorable.Windows {
+		for j, p := range w.Panes {
+			if programs := tmux.PaneCommands(session.Pane{Command: p.Command}, cfg); len(programs) > 0 {
+				relaunched = append(relaunched, fmt.Sprintf("#   window %d pane %d: %s", i+1, j+1, programs[0]))
+			}
+		}
+	}
+	if len(relaunched) == 0 {
+		fmt.Println("# no programs are relaunched")
+		return nil
+	}
+	fmt.Println("# relaunched programs:")
+	for _, line := range relaunched {
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// tmuxCommandLine quotes a tmux invocation for the shell.
+func tmuxCommandLine(args []string) string {
+	words := []string{"tmux"}
+	for _, arg := range args {
+		words = append(words, exporter.Quote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+func runSet(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("set", flag.ExitOnError)
+	pinned := fs.Bool("pinned", false, "List the session first and never prune it")
+	autostart := fs.Bool("autostart", false, "Restore the session on boot")
+	frozen := fs.Bool("frozen", false, "Never save over the session's layout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-tms set [-pinned[=false]] [-autostart[=false]] [-frozen[=false]] SESSION")
+		fmt.Fprintln(fs.Output(), "Prints the session's flags when none are given.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one session name")
+	}
+
+	// Only the target entry changes; other saved layouts are left as they
+	// are and a running session is only added when it was never saved.
+	sessions, err := session.LoadSessionsFromDisk()
+	if err != nil {
+		return err
+	}
+	isTarget := func(s session.Session) bool { return s.Name == fs.Arg(0) }
+	i := slices.IndexFunc(sessions, isTarget)
+	if i < 0 {
+		tmuxSessions, err := tmux.ListSessions(cfg)
+		if err != nil {
+			return err
+		}
+		j := slices.IndexFunc(tmuxSessions, isTarget)
+		if j < 0 {
+			return fmt.Errorf("session not found: %s", fs.Arg(0))
+		}
+		sessions = append(sessions, tmuxSessions[j])
+		i = len(sessions) - 1
+	}
+	sessionInstance := &sessions[i]
+	changed := false
+	fs.Visit(func(f *flag.Flag) {
+		changed = true
+		switch f.Name {
+		case "pinned":
+			sessionInstance.Pinned = *pinned
+		case "autostart":
+			sessionInstance.Autostart = *autostart
+		case "frozen":
+			sessionInstance.Frozen = *frozen
+		}
+	})
+	if changed {
+		if err := session.SaveSessionsToDisk(sessions); err != nil {
+			return err
+		}
+	}
+	flags := sessionInstance.Flags()
+	if len(flags) == 0 {
+		flags = []string{"no flags"}
+	}
+	fmt.Printf("%s: %s\n", sessionInstance.Name, strings.Join(flags, ", "))
+	return nil
+}
+
+func runConfig(args []string, cfg *config.Config) error {
+	usage := "usage: go-tms config check|show|init|diff"
+	if len(args) == 0 {
+		return fmt.Errorf("%s", usage)
+	}
+	switch args[0] {
+	case "check":
+		return runConfigCheck(args[1:])
+	case "show":
+		return runConfigShow(args[1:])
+	case "init":
+		return runConfigInit(args[1:])
+	case "diff":
+		return runConfigDiff(args[1:])
+	}
+	return fmt.Errorf("unknown config command: %s (%s)", args[0], usage)
+}
+
+func runConfigCheck(args []string) error {
+	fs := flag.NewFlagSet("config check", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-tms config check")
+		fmt.Fprintln(fs.Output(), "Reports unknown keys and invalid values in every config layer.")
+	}
+	fs.Parse(args)
+
+	path, err := config.Path()
+	if err != nil {
+		return err
+	}
+	if _, err := config.LoadConfig(); err != nil {
+		return err
+	}
+	fmt.Printf("%s: ok\n", path)
+	return nil
+}
+
+func runConfigShow(args []string) error {
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	origin := fs.Bool("origin", false, "Annotate every key with the file, variable or flag that set it")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-tms config show [-origin]")
+		fmt.Fprintln(fs.Output(), "Prints the effective configuration.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	cfg, origins, err := config.LoadConfigWithOrigins()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	if !*origin {
+		origins = nil
+	}
+	data, err := config.Marshal(&cfg, origins)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func runConfigInit(args []string) error {
+	fs := flag.NewFlagSet("config init", flag.ExitOnError)
+	output := fs.String("o", "", "Write to FILE instead of the config path, - for stdout")
+	force := fs.Bool("force", false, "Overwrite an existing file")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-tms config init [-o FILE] [-force]")
+		fmt.Fprintln(fs.Output(), "Writes a config file documenting every key and its default.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	data, err := config.Document()
+	if err != nil {
+		return err
+	}
+	if *output == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	path := *output
+	if path == "" {
+		if path, err = config.Path(); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(path); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", path)
+	return nil
+}
+
+func runConfigDiff(args []string) error {
+	fs := flag.NewFlagSet("config diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-tms config diff")
+		fmt.Fprintln(fs.Output(), "Shows the keys set by config files, variables or flags next to their defaults.")
+	}
+	fs.Parse(args)
+
+	cfg, origins, err := config.LoadConfigWithOrigins()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	diff, err := config.Diff(&cfg, origins)
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		fmt.Println("# every key has its default value")
+		return nil
+	}
+	fmt.Print(diff)
+	return nil
+}
+
+func runPlugins(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("plugins", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-tms plugins")
+		fmt.Fprintln(fs.Output(), "Lists the "+plugin.Prefix+"* executables on PATH, run as go-tms NAME.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	manifests, err := plugin.LoadManifests()
+	if err != nil {
+		return err
+	}
+	for _, name := range plugin.List() {
+		i := slices.IndexFunc(manifests, func(m plugin.Manifest) bool { return m.Name == name })
+		if i < 0 {
+			fmt.Println(name)
+			continue
+		}
+		fmt.Printf("%s (%d actions, %d sources)\n", name, len(manifests[i].Actions), len(manifests[i].Sources))
+	}
+	return nil
+}
+
+func runLast(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("last", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-tms last")
+		fmt.Fprintln(fs.Output(), "Switches to the previously used session, restoring it if it is not running.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	sessions, err := loadAllSessions(cfg)
+	if err != nil {
+		return err
+	}
+	current, err := tmux.CurrentSession()
+	if err != nil {
+		return err
+	}
+	// tmux knows about switches made without go-tms, the history about
+	// sessions that have been closed since.
+	previous, err := tmux.LastSession()
+	if err != nil {
+		return err
+	}
+	if previous == current || !session.CheckIfSessionExists(previous, sessions) {
+		history, err := session.LoadHistory()
+		if err != nil {
+			return err
+		}
+		history.Entries = slices.DeleteFunc(history.Entries, func(a session.Access) bool {
+			return !session.CheckIfSessionExists(a.Name, sessions)
+		})
+		previous = history.Previous(current)
+	}
+	if previous == "" {
+		return fmt.Errorf("no previous session")
+	}
+	return handleSessionLogic(false, previous, &sessions, cfg)
+}
+
+func runSlot(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("slot", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: go-tms slot [N | set N [SESSION] | clear N]\n")
+		fmt.Fprintf(fs.Output(), "Switches to the session in quick slot N (1-%d), restoring it if it is not running.\n", session.MaxSlot)
+		fmt.Fprintln(fs.Output(), "set stores SESSION, by default the current one, in the slot. Without arguments the slots are listed.")
+		fmt.Fprintln(fs.Output(), "Example tmux binding: bind-key -n M-1 run-shell 'go-tms slot 1'")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	slots, err := session.LoadSlots()
+	if err != nil {
+		return err
+	}
+	switch {
+	case fs.NArg() == 0:
+		for n := 1; n <= session.MaxSlot; n++ {
+			if name, ok := slots[n]; ok {
+				fmt.Printf("%d: %s\n", n, name)
+			}
+		}
+		return nil
+	case fs.Arg(0) == "set" && (fs.NArg() == 2 || fs.NArg() == 3):
+		n, err := session.ParseSlot(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		name := fs.Arg(2)
+		if name == "" {
+			if name, err = tmux.CurrentSession(); err != nil {
+				return err
+			}
+			if name == "" {
+				return fmt.Errorf("not in a tmux session, name the session to store")
+			}
+		}
+		sessions, err := loadAllSessions(cfg)
+		if err != nil {
+			return err
+		}
+		if !session.CheckIfSessionExists(name, sessions) {
+			return fmt.Errorf("session not found: %s", name)
+		}
+		slots[n] = name
+		if err := session.SaveSlots(slots); err != nil {
+			return err
+		}
+		fmt.Printf("%d: %s\n", n, name)
+		return nil
+	case fs.Arg(0) == "clear" && fs.NArg() == 2:
+		n, err := session.ParseSlot(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		delete(slots, n)
+		return session.SaveSlots(slots)
+	case fs.NArg() == 1:
+		n, err := session.ParseSlot(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		name, ok := slots[n]
+		if !ok {
+			return fmt.Errorf("slot %d is empty", n)
+		}
+		sessions, err := loadAllSessions(cfg)
+		if err != nil {
+			return err
+		}
+		if !session.CheckIfSessionExists(name, sessions) {
+			return fmt.Errorf("session %s in slot %d no longer exists", name, n)
+		}
+		return handleSessionLogic(false, name, &sessions, cfg)
+	}
+	fs.Usage()
+	return fmt.Errorf("invalid slot command")
+}
+
+func runTemplate(args []string, cfg *config.Config) error {
+	fs := flag.NewFlagSet("template", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: go-tms template trust [DIR]")
+		fmt.Fprintln(fs.Output(), "Trusts the current content of DIR/"+session.ProjectTemplateFile+" and the file it references, by default in the working directory.")
+		fmt.Fprintln(fs.Output(), "Untrusted or modified project templates are ignored when creating sessions.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 || fs.Arg(0) != "trust" {
+		fs.Usage()
+		return fmt.Errorf("expected template trust [DIR]")
+	}
+
+	dir := fs.Arg(1)
+	if dir == "" {
+		var err error
+		if dir, err = os.Getwd(); err != nil {
+			return err
+		}
+	}
+	projectFile, err := session.TrustProjectTemplate(dir)
+	if err != nil {
+		return fmt.Errorf("failed to trust project template: %v", err)
+	}
+	fmt.Printf("Trusted %s\n", projectFile)
+	return nil
+}
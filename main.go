@@ -5,16 +5,24 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
-	"regexp"
 	"runtime/debug"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/swit33/go-tms/pkg/boot"
 	"github.com/swit33/go-tms/pkg/config"
 	"github.com/swit33/go-tms/pkg/daemon"
+	"github.com/swit33/go-tms/pkg/exporter"
 	"github.com/swit33/go-tms/pkg/fzf"
+	"github.com/swit33/go-tms/pkg/git"
+	"github.com/swit33/go-tms/pkg/hooks"
+	"github.com/swit33/go-tms/pkg/importer"
 	"github.com/swit33/go-tms/pkg/interfaces"
+	"github.com/swit33/go-tms/pkg/plugin"
+	"github.com/swit33/go-tms/pkg/projects"
 	"github.com/swit33/go-tms/pkg/session"
 	"github.com/swit33/go-tms/pkg/tmux"
 )
@@ -26,13 +34,56 @@ func main() {
 	bootNoDaemon := flag.Bool("B", false, "Run in boot mode without daemon")
 	switcherMode := flag.Bool("s", false, "Run in switcher mode")
 	version := flag.Bool("V", false, "Print version")
+	configFile := flag.String("c", "", "Config file (default $"+config.PathEnv+" or $XDG_CONFIG_HOME/go-tms/config.yaml)")
+	var overrides []string
+	flag.Func("set", "Override a config key for this invocation, as key=value (repeatable)", func(o string) error {
+		overrides = append(overrides, o)
+		return nil
+	})
 
 	flag.Parse()
 
+	// Child processes such as the daemon find the config through the
+	// environment.
+	if *configFile != "" {
+		path, err := filepath.Abs(*configFile)
+		if err != nil {
+			handleError(err)
+		}
+		os.Setenv(config.PathEnv, path)
+	}
+
+	config.SetOverrides(overrides)
+
+	// An invalid config falls back to defaults for the offending keys; the
+	// config command reports the problems itself.
 	cfg, err := config.LoadConfig()
 	if err != nil {
+		if flag.NArg() == 0 {
+			handleError(err)
+		} else if flag.Arg(0) != "config" {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	if err := session.SetPathRoots(cfg.PathRoots); err != nil {
 		handleError(err)
 	}
+	if cfg.SessionStore != "" {
+		session.SetStorePath(session.ExpandPath(cfg.SessionStore))
+	}
+	if moved, err := session.MigrateStore(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to migrate session store: %v\n", err)
+	} else if len(moved) > 0 {
+		fmt.Fprintf(os.Stderr, "Moved session store to %s\n", filepath.Dir(moved[0]))
+	}
+
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Args(), &cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	if *daemonMode {
 		daemon.RunDaemon(&cfg)
@@ -56,6 +107,7 @@ func main() {
 	}
 
 	if *switcherMode {
+		registerPluginActions(&cfg)
 		err = runSwitcher(&cfg)
 		if err != nil {
 			handleError(err)
@@ -69,15 +121,19 @@ func main() {
 }
 
 func runSwitcher(cfg *config.Config) error {
-	sessions, err := session.LoadSessionsFromDisk()
-	if err != nil {
-		return err
-	}
-	tmuxSessions, err := tmux.ListSessions(cfg)
-	if err != nil {
-		return err
+	if cfg.AutoPrune {
+		sessions, err := session.LoadSessionsFromDisk()
+		if err != nil {
+			return err
+		}
+		kept, pruned := session.PruneStale(sessions)
+		if len(pruned) > 0 {
+			if err := session.ReplaceSessionsOnDisk(kept); err != nil {
+				return err
+			}
+		}
 	}
-	combinedSessions, err := session.CombineSessions(tmuxSessions, sessions)
+	combinedSessions, err := listSessions(cfg)
 	if err != nil {
 		return err
 	}
@@ -92,6 +148,88 @@ func runSwitcher(cfg *config.Config) error {
 	return nil
 }
 
+// listSessions merges the running and saved sessions, in the configured
+// order, with the entries of the picker sources, which follow in source
+// order. The sessions must load since actions save them back to the store; a
+// failing picker source is only reported.
+func listSessions(cfg *config.Config) ([]session.Session, error) {
+	sessions, err := session.Merge(tmux.SessionSource(cfg), session.StoreSource())
+	if err != nil {
+		return nil, err
+	}
+	if err := sortSessions(sessions, cfg); err != nil {
+		return nil, err
+	}
+	listed := session.NewSource("Sessions", func([]session.Session) ([]session.Session, error) {
+		return sessions, nil
+	})
+	combined, err := session.Merge(append([]session.Source{listed}, pickerSources(cfg)...)...)
+	if err != nil {
+		// The picker still lists the entries of the other sources.
+		tmux.SendMsg(err.Error())
+	}
+	return combined, nil
+}
+
+// registerPluginActions adds the picker actions of plugin manifests to the
+// configured ones.
+func registerPluginActions(cfg *config.Config) {
+	manifests, err := plugin.LoadManifests()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return
+	}
+	var taken []string
+	for _, b := range fzf.Bindings(cfg) {
+		taken = append(taken, b.Key)
+	}
+	actions, problems := plugin.Actions(manifests, taken)
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", p)
+	}
+	cfg.Actions = append(cfg.Actions, actions...)
+}
+
+// pickerSources returns the sources configured by picker-sources.
+func pickerSources(cfg *config.Config) []session.Source {
+	var sources []session.Source
+	for name := range strings.SplitSeq(cfg.PickerSources, ",") {
+		switch strings.TrimSpace(name) {
+		case "worktrees":
+			if cfg.GitAwareSessions {
+				sources = append(sources, git.WorktreeSource(cfg.GitSessionTemplate))
+			}
+		case "templates":
+			sources = append(sources, session.TemplateSource(cfg.Templates))
+		case "projects":
+			sources = append(sources, projects.Source(cfg))
+		case "zoxide":
+			sources = append(sources, projects.ZoxideSource())
+		case "plugins":
+			manifests, err := plugin.LoadManifests()
+			if err != nil {
+				tmux.SendMsg(err.Error())
+				continue
+			}
+			sources = append(sources, plugin.Sources(manifests, pluginContext(nil))...)
+		}
+	}
+	return sources
+}
+
+// pluginContext describes go-tms to a plugin, with the picker entry an
+// action runs for if any.
+func pluginContext(selected *plugin.Selected) plugin.Context {
+	ctx := plugin.Context{Selected: selected}
+	ctx.ConfigPath, _ = config.Path()
+	ctx.StorePath, _ = session.GetSessionStorePath()
+	ctx.Session, _ = tmux.CurrentSession()
+	if socket, _, ok := strings.Cut(os.Getenv("TMUX"), ","); ok {
+		ctx.TmuxSocket = socket
+	}
+	return ctx
+}
+
 func handleResult(result fzf.Result, sessions *[]session.Session, cfg *config.Config) error {
 	if result.IsAction {
 		switch result.Action {
@@ -100,15 +238,22 @@ func handleResult(result fzf.Result, sessions *[]session.Session, cfg *config.Co
 			if err != nil {
 				return err
 			}
-			return handleActionNew(cwd, sessions, cfg)
+			return handleActionNew(cwd, "", sessions, cfg)
 		case fzf.ActionDelete:
 			return handleActionDelete(result, sessions, cfg)
 		case fzf.ActionInteractive:
-			return handleZoxide(sessions, cfg)
+			return handleInteractive(sessions, cfg)
 		case fzf.ActionSave:
 			return handleSave(sessions, cfg)
 		case fzf.ActionKill:
-			return handleActionKill(result, cfg)
+			return handleActionKill(result, sessions, cfg)
+		case fzf.ActionWorktree:
+			return handleActionWorktree(result, sessions, cfg)
+		case fzf.ActionPin, fzf.ActionAutostart, fzf.ActionFreeze:
+			return handleActionToggle(result, sessions, cfg)
+		}
+		if i, ok := result.Action.Custom(); ok && i < len(cfg.Actions) {
+			return handleActionCustom(cfg.Actions[i], result, sessions, cfg)
 		}
 	} else {
 		return handleSessionLogic(false, result.SessionName, sessions, cfg)
@@ -116,14 +261,58 @@ func handleResult(result fzf.Result, sessions *[]session.Session, cfg *config.Co
 	return nil
 }
 
+func sortSessions(sessions []session.Session, cfg *config.Config) error {
+	history, err := session.LoadHistory()
+	if err != nil {
+		return err
+	}
+	current, err := tmux.CurrentSession()
+	if err != nil {
+		return err
+	}
+	session.SortSessions(sessions, history, cfg.SessionOrder, current, time.Now())
+	return nil
+}
+
+// recordSwitch updates the access history after switching to a session.
+// The previous session is captured by tmux as the client's last session.
+func recordSwitch(sessionName string) error {
+	previous, err := tmux.LastSession()
+	if err != nil {
+		return err
+	}
+	return session.RecordSwitch(previous, sessionName)
+}
+
 func handleSave(sessions *[]session.Session, cfg *config.Config) error {
+	if err := hooks.Pre(cfg, hooks.Save, hooks.Context{}); err != nil {
+		return err
+	}
 	err := session.SaveSessionsToDisk(*sessions)
 	if err != nil {
 		return err
 	}
+	postHook(cfg, hooks.Save, hooks.Context{})
 	return runSwitcher(cfg)
 }
 
+// hookContext describes the named session to hooks.
+func hookContext(name string, sessions []session.Session) hooks.Context {
+	ctx := hooks.Context{Name: name}
+	if sessionInstance, err := session.GetSessionByName(name, sessions); err == nil {
+		ctx.Path = sessionInstance.CurrentPath
+	}
+	return ctx
+}
+
+// postHook runs the post- hook of an event that already happened, so a
+// failure is only reported.
+func postHook(cfg *config.Config, event hooks.Event, ctx hooks.Context) {
+	if err := hooks.Post(cfg, event, ctx); err != nil {
+		tmux.SendMsg(err.Error())
+	}
+}
+
 func handleSessionLogic(ispath bool, identifier string, sessions *[]session.Session, cfg *config.Config) error {
 	runner := interfaces.OsRunner{}
 
@@ -132,18 +321,55 @@ func handleSessionLogic(ispath bool, identifier string, sessions *[]session.Sess
 		return err
 	}
 	if sessionName != "" {
-		return tmux.SwitchSession(sessionName, runner)
+		ctx := hookContext(sessionName, *sessions)
+		if err := hooks.Pre(cfg, hooks.Switch, ctx); err != nil {
+			return err
+		}
+		if err := tmux.SwitchSession(sessionName, runner); err != nil {
+			return err
+		}
+		postHook(cfg, hooks.Switch, ctx)
+		return recordSwitch(sessionName)
 	}
 	sessionInstance, err := session.GetSessionByName(identifier, *sessions)
+	if err == nil && sessionInstance.Source != "" {
+		return handleActionNew(sessionInstance.CurrentPath, sessionInstance.Name, sessions, cfg)
+	}
 	if err == nil {
-		return tmux.RestoreSession(sessionInstance, interfaces.OsRunner{}, cfg)
+		restorable, problems, err := tmux.PrepareRestore(sessionInstance, cfg)
+		if err != nil {
+			return err
+		}
+		ctx := hooks.Context{Name: restorable.Name, Path: restorable.CurrentPath}
+		if err := hooks.Pre(cfg, hooks.Restore, ctx); err != nil {
+			return err
+		}
+		if err := tmux.RestoreSession(restorable, interfaces.OsRunner{}, cfg); err != nil {
+			return err
+		}
+		postHook(cfg, hooks.Restore, ctx)
+		if len(problems) > 0 {
+			messages := make([]string, 0, len(problems))
+			for _, p := range problems {
+				messages = append(messages, p.String())
+			}
+			tmux.SendMsg(fmt.Sprintf("Restored %s with changes: %s", sessionInstance.Name, strings.Join(messages, "; ")))
+		}
+		return recordSwitch(sessionInstance.Name)
 	}
 
-	return handleActionNew(identifier, sessions, cfg)
+	return handleActionNew(identifier, "", sessions, cfg)
 }
 
-func handleZoxide(sessions *[]session.Session, cfg *config.Config) error {
-	result, err := fzf.RunZoxide(cfg)
+func handleInteractive(sessions *[]session.Session, cfg *config.Config) error {
+	dirs, err := projects.Directories(cfg)
+	if err != nil {
+		return err
+	}
+	if len(dirs) == 0 {
+		return fmt.Errorf("no directories found: configure project-roots or install zoxide")
+	}
+	result, err := fzf.RunDirectories(dirs, cfg)
 	if err != nil {
 		return err
 	}
@@ -153,10 +379,28 @@ func handleZoxide(sessions *[]session.Session, cfg *config.Config) error {
 	return handleSessionLogic(true, result.Arg, sessions, cfg)
 }
 
-func handleActionNew(path string, sessions *[]session.Session, cfg *config.Config) error {
+// handleActionNew creates a session for path, named preferred when given and
+// not taken.
+func handleActionNew(path string, preferred string, sessions *[]session.Session, cfg *config.Config) error {
 	runner := interfaces.OsRunner{}
 
-	name, err := findUniqueSessionName(path, *sessions)
+	name, err := findUniqueSessionName(path, preferred, *sessions, cfg)
+	if err != nil {
+		return err
+	}
+	ctx := hooks.Context{Name: name, Path: path}
+	if err := hooks.Pre(cfg, hooks.Create, ctx); err != nil {
+		return err
+	}
+	template, hasTemplate, err := session.FindTemplate(path, cfg.Templates)
+	if err != nil {
+		return err
+	}
+	if exists, trusted, _ := session.ProjectTemplateTrusted(path); exists && !trusted {
+		tmux.SendMsg(fmt.Sprintf("Ignoring untrusted %s, review it and run: go-tms template trust %s",
+			session.ProjectTemplateFile, path))
+	}
+	template, err = importer.ResolveTemplate(template)
 	if err != nil {
 		return err
 	}
@@ -166,11 +410,24 @@ func handleActionNew(path string, sessions *[]session.Session, cfg *config.Confi
 			return err
 		}
 	}
-	sessionName, err := tmux.CreateNewSession(name, path, runner)
-	if err != nil {
-		return err
+	if hasTemplate {
+		sessionInstance, err := template.Instantiate(name, path)
+		if err != nil {
+			return err
+		}
+		if err := tmux.RestoreSession(&sessionInstance, runner, cfg); err != nil {
+			return err
+		}
+	} else {
+		if _, err := tmux.CreateNewSession(name, path, runner); err != nil {
+			return err
+		}
+		if err := tmux.SwitchSession(name, runner); err != nil {
+			return err
+		}
 	}
-	if err := tmux.SwitchSession(sessionName, runner); err != nil {
+	postHook(cfg, hooks.Create, ctx)
+	if err := recordSwitch(name); err != nil {
 		return err
 	}
 	tmuxSessions, err := tmux.ListSessions(cfg)
@@ -187,6 +444,7 @@ func handleActionNew(path string, sessions *[]session.Session, cfg *config.Confi
 func handleActionDelete(result fzf.Result, sessions *[]session.Session, cfg *config.Config) error {
 	var err error
 	sessionName := result.Arg
+	ctx := hookContext(sessionName, *sessions)
 	if session.CheckIfSessionExists(sessionName, *sessions) {
 		*sessions, err = session.DeleteSession(sessionName, *sessions)
 		if err != nil {
@@ -198,10 +456,14 @@ func handleActionDelete(result fzf.Result, sessions *[]session.Session, cfg *con
 		return err
 	}
 	if sessionName != "" {
+		if err := hooks.Pre(cfg, hooks.Kill, ctx); err != nil {
+			return err
+		}
 		err = tmux.DeleteSession(sessionName)
 		if err != nil {
 			return err
 		}
+		postHook(cfg, hooks.Kill, ctx)
 	}
 	err = session.SaveSessionsToDisk(*sessions)
 	if err != nil {
@@ -211,8 +473,65 @@ func handleActionDelete(result fzf.Result, sessions *[]session.Session, cfg *con
 	return runSwitcher(cfg)
 }
 
-// func handleActionKill(result fzf.Result, sessions *[]session.Session, cfg *config.Config) error {
-func handleActionKill(result fzf.Result, cfg *config.Config) error {
+// handleActionToggle flips the session flag bound to the action and saves it.
+func handleActionToggle(result fzf.Result, sessions *[]session.Session, cfg *config.Config) error {
+	i := slices.IndexFunc(*sessions, func(s session.Session) bool { return s.Name == result.Arg })
+	if i < 0 {
+		return fmt.Errorf("session not found: %s", result.Arg)
+	}
+	sessionInstance := &(*sessions)[i]
+	if sessionInstance.Source != "" {
+		return fmt.Errorf("%s is not a session yet", sessionInstance.Name)
+	}
+	switch result.Action {
+	case fzf.ActionPin:
+		sessionInstance.Pinned = !sessionInstance.Pinned
+	case fzf.ActionAutostart:
+		sessionInstance.Autostart = !sessionInstance.Autostart
+	case fzf.ActionFreeze:
+		sessionInstance.Frozen = !sessionInstance.Frozen
+	}
+	if err := session.SaveSessionsToDisk(*sessions); err != nil {
+		return err
+	}
+	return runSwitcher(cfg)
+}
+
+// handleActionCustom runs a configured action's command for the selected
+// session.
+func handleActionCustom(action config.Action, result fzf.Result, sessions *[]session.Session, cfg *config.Config) error {
+	var path string
+	if sessionInstance, err := session.GetSessionByName(result.Arg, *sessions); err == nil {
+		path = sessionInstance.CurrentPath
+	}
+	if action.Plugin != "" {
+		if err := plugin.Run(action.Plugin, action.Args, pluginContext(&plugin.Selected{Name: result.Arg, Path: path})); err != nil {
+			return err
+		}
+	} else {
+		cmd := exec.Command("sh", "-c", actionCommand(action.Command, result.Arg, path))
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			cmd.Dir = path
+		}
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("action %s failed: %v", action.Label, err)
+		}
+	}
+	if action.Reload {
+		return runSwitcher(cfg)
+	}
+	return nil
+}
+
+// actionCommand fills in the shell quoted session name and path.
+func actionCommand(template string, name string, path string) string {
+	return strings.NewReplacer("{name}", exporter.Quote(name), "{path}", exporter.Quote(path)).Replace(template)
+}
+
+func handleActionKill(result fzf.Result, sessions *[]session.Session, cfg *config.Config) error {
 	var err error
 	sessionName := result.Arg
 	sessionName, err = tmux.CheckIfSessionExists(false, sessionName)
@@ -220,15 +539,89 @@ func handleActionKill(result fzf.Result, cfg *config.Config) error {
 		return err
 	}
 	if sessionName != "" {
+		ctx := hookContext(sessionName, *sessions)
+		if err := hooks.Pre(cfg, hooks.Kill, ctx); err != nil {
+			return err
+		}
 		err = tmux.KillSession(sessionName)
 		if err != nil {
 			return err
 		}
+		postHook(cfg, hooks.Kill, ctx)
 	}
 	return runSwitcher(cfg)
 }
 
-func findUniqueSessionName(startPath string, savedSessions []session.Session) (string, error) {
+// handleActionWorktree creates a new worktree of the selected session's
+// repository and opens a session for it.
+func handleActionWorktree(result fzf.Result, sessions *[]session.Session, cfg *config.Config) error {
+	path, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if sessionInstance, err := session.GetSessionByName(result.Arg, *sessions); err == nil {
+		path = sessionInstance.CurrentPath
+	}
+	repo, ok, err := git.Detect(path)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("not a git repository: %s", path)
+	}
+
+	branch, err := prompt(fmt.Sprintf("New worktree of %s, branch: ", repo.Name))
+	if err != nil {
+		return err
+	}
+	if branch == "" {
+		return runSwitcher(cfg)
+	}
+	worktreePath := git.WorktreePath(cfg.GitWorktreePath, repo, branch)
+	if err := git.AddWorktree(repo, worktreePath, branch); err != nil {
+		return err
+	}
+	return handleActionNew(worktreePath, "", sessions, cfg)
+}
+
+func findUniqueSessionName(startPath string, preferred string, savedSessions []session.Session, cfg *config.Config) (string, error) {
+	isTaken := func(name string) (bool, error) {
+		tmuxName, err := tmux.CheckIfSessionExists(false, name)
+		if err != nil {
+			return false, err
+		}
+		sessionInstance, err := session.GetSessionByName(name, savedSessions)
+		sessionExistsOnDisk := err == nil && sessionInstance.Source == ""
+		return tmuxName != "" || sessionExistsOnDisk, nil
+	}
+
+	if preferred != "" {
+		taken, err := isTaken(preferred)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return preferred, nil
+		}
+	}
+
+	if cfg.GitAwareSessions {
+		repo, ok, err := git.Detect(startPath)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			name := session.SanitizeName(git.SessionName(cfg.GitSessionTemplate, repo))
+			taken, err := isTaken(name)
+			if err != nil {
+				return "", err
+			}
+			if !taken {
+				return name, nil
+			}
+		}
+	}
+
 	path := startPath
 	var nameParts []string
 
@@ -237,18 +630,13 @@ func findUniqueSessionName(startPath string, savedSessions []session.Session) (s
 		nameParts = append([]string{namePart}, nameParts...)
 
 		sessionName := strings.Join(nameParts, "-")
+		sanitizedName := session.SanitizeName(sessionName)
 
-		re := regexp.MustCompile(`[^a-zA-Z0-9_-]`)
-		sanitizedName := re.ReplaceAllString(sessionName, "_")
-
-		tmuxName, err := tmux.CheckIfSessionExists(false, sanitizedName)
+		taken, err := isTaken(sanitizedName)
 		if err != nil {
 			return "", err
 		}
-
-		sessionExistsOnDisk := session.CheckIfSessionExists(sanitizedName, savedSessions)
-
-		if tmuxName == "" && !sessionExistsOnDisk {
+		if !taken {
 			return sanitizedName, nil
 		}
 
@@ -269,6 +657,15 @@ func handleError(err error) {
 	}
 }
 
+func prompt(label string) (string, error) {
+	fmt.Print(label)
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		return "", scanner.Err()
+	}
+	return strings.TrimSpace(scanner.Text()), nil
+}
+
 func printVersion() {
 	buildInfo, ok := debug.ReadBuildInfo()
 	if !ok {
@@ -5,6 +5,7 @@ import (
 	"github.com/swit33/go-tms/pkg/config"
 	"github.com/swit33/go-tms/pkg/daemon"
 	"github.com/swit33/go-tms/pkg/interfaces"
+	"github.com/swit33/go-tms/pkg/session"
 	"github.com/swit33/go-tms/pkg/tmux"
 	"os"
 	"os/exec"
@@ -27,7 +28,15 @@ func RunBoot(cfg *config.Config, daemonMode bool) error {
 			daemon.StartDaemon(cfg)
 		}
 
-		_, err = tmux.CreateBootSession("go-tms-startup", self, interfaces.OsRunner{})
+		// A failed restore is summarized but does not prevent attaching.
+		err := restoreSelected(cfg, os.Stdout, func(saved []session.Session) []session.Session {
+			return BootSessions(saved, cfg)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+
+		_, err = tmux.CreateBootSession(tmux.StartupSession, self, interfaces.OsRunner{})
 		if err != nil {
 			return err
 		}
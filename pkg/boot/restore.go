@@ -0,0 +1,113 @@
+package boot
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/hooks"
+	"github.com/swit33/go-tms/pkg/interfaces"
+	"github.com/swit33/go-tms/pkg/session"
+	"github.com/swit33/go-tms/pkg/tmux"
+)
+
+// SelectSessions returns the sessions whose names match one of the comma
+// separated glob patterns. An empty pattern list selects every session.
+func SelectSessions(sessions []session.Session, patterns string) []session.Session {
+	if strings.TrimSpace(patterns) == "" {
+		return sessions
+	}
+	var selected []session.Session
+	for _, s := range sessions {
+		for _, pattern := range strings.Split(patterns, ",") {
+			if ok, _ := path.Match(strings.TrimSpace(pattern), s.Name); ok {
+				selected = append(selected, s)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// RestoreAll recreates the saved sessions selected by the restore-sessions
+// option.
+func RestoreAll(cfg *config.Config, out io.Writer) error {
+	return restoreSelected(cfg, out, func(saved []session.Session) []session.Session {
+		return SelectSessions(saved, cfg.RestoreSessions)
+	})
+}
+
+// BootSessions returns the sessions to bring back on boot: those marked
+// autostart, and with boot-restore the ones selected by restore-sessions.
+func BootSessions(saved []session.Session, cfg *config.Config) []session.Session {
+	var selected []session.Session
+	if cfg.BootRestore {
+		selected = SelectSessions(saved, cfg.RestoreSessions)
+	}
+	for _, s := range saved {
+		if s.Autostart && !session.CheckIfSessionExists(s.Name, selected) {
+			selected = append(selected, s)
+		}
+	}
+	return selected
+}
+
+// restoreSelected recreates the selected saved sessions that are not
+// running, without switching to them. Progress is written to out, followed
+// by a summary of the sessions that failed.
+func restoreSelected(cfg *config.Config, out io.Writer, selectSessions func([]session.Session) []session.Session) error {
+	saved, err := session.LoadSessionsFromDisk()
+	if err != nil {
+		return err
+	}
+	running, err := tmux.ListSessions(cfg)
+	if err != nil {
+		return err
+	}
+
+	var pending []session.Session
+	for _, s := range selectSessions(saved) {
+		if _, err := session.GetSessionByName(s.Name, running); err != nil {
+			pending = append(pending, s)
+		}
+	}
+
+	var failures []string
+	for i, s := range pending {
+		fmt.Fprintf(out, "[%d/%d] %s ", i+1, len(pending), s.Name)
+		ctx := hooks.Context{Name: s.Name, Path: s.CurrentPath}
+		restorable, problems, err := tmux.PrepareRestore(&s, cfg)
+		if err == nil {
+			err = hooks.Pre(cfg, hooks.Restore, ctx)
+		}
+		if err == nil {
+			err = tmux.RestoreSessionDetached(restorable, interfaces.OsRunner{}, cfg)
+		}
+		if err != nil {
+			fmt.Fprintln(out, "failed")
+			failures = append(failures, fmt.Sprintf("%s: %v", s.Name, err))
+			continue
+		}
+		fmt.Fprintln(out, "restored")
+		if err := hooks.Post(cfg, hooks.Restore, ctx); err != nil {
+			fmt.Fprintf(out, "    %v\n", err)
+		}
+		for _, p := range problems {
+			fmt.Fprintf(out, "    %s\n", p)
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+	fmt.Fprintf(out, "%d of %d sessions restored\n", len(pending)-len(failures), len(pending))
+	if len(failures) > 0 {
+		for _, f := range failures {
+			fmt.Fprintf(out, "  %s\n", f)
+		}
+		return fmt.Errorf("%d of %d sessions failed to restore", len(failures), len(pending))
+	}
+	return nil
+}
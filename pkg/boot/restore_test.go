@@ -0,0 +1,59 @@
+package boot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/session"
+)
+
+func TestSelectSessions(t *testing.T) {
+	sessions := []session.Session{{Name: "notes"}, {Name: "infra"}, {Name: "go-tms/main"}, {Name: "blog"}}
+	cases := map[string][]string{
+		"":                   {"notes", "infra", "go-tms/main", "blog"},
+		"notes, infra":       {"notes", "infra"},
+		"go-tms/*":           {"go-tms/main"},
+		"*o*":                {"notes", "blog"},
+		"missing":            nil,
+		"blog,blog,b*":       {"blog"},
+		"infra,go-tms/main ": {"infra", "go-tms/main"},
+	}
+	for patterns, expected := range cases {
+		var got []string
+		for _, s := range SelectSessions(sessions, patterns) {
+			got = append(got, s.Name)
+		}
+		if len(got) != len(expected) {
+			t.Errorf("SelectSessions(%q) = %v, expected %v", patterns, got, expected)
+			continue
+		}
+		for i := range got {
+			if got[i] != expected[i] {
+				t.Errorf("SelectSessions(%q) = %v, expected %v", patterns, got, expected)
+				break
+			}
+		}
+	}
+}
+
+func TestBootSessions(t *testing.T) {
+	saved := []session.Session{{Name: "notes", Autostart: true}, {Name: "infra"}, {Name: "blog", Autostart: true}}
+	cases := []struct {
+		cfg      config.Config
+		expected []string
+	}{
+		{config.Config{}, []string{"notes", "blog"}},
+		{config.Config{BootRestore: true, RestoreSessions: "infra"}, []string{"infra", "notes", "blog"}},
+		{config.Config{BootRestore: true}, []string{"notes", "infra", "blog"}},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, s := range BootSessions(saved, &c.cfg) {
+			got = append(got, s.Name)
+		}
+		if strings.Join(got, ",") != strings.Join(c.expected, ",") {
+			t.Errorf("BootSessions(%+v) = %v, expected %v", c.cfg, got, c.expected)
+		}
+	}
+}
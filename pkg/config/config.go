@@ -4,47 +4,127 @@ import (
 	"os"
 	"path/filepath"
 
-	"gopkg.in/yaml.v3"
+	"github.com/swit33/go-tms/pkg/session"
+	"github.com/swit33/go-tms/pkg/xdg"
 )
 
 var configPath string = filepath.Join("go-tms", "config.yaml")
 
 type Config struct {
-	AutoSaveIntervalMinutes int    `yaml:"auto-save-interval-minutes"`
-	FZFBindNew              string `yaml:"fzf-bind-new"`
-	FZFBindDelete           string `yaml:"fzf-bind-delete"`
-	FZFBindInteractive      string `yaml:"fzf-bind-interactive"`
-	FZFBindSave             string `yaml:"fzf-bind-save"`
-	FZFBindKill             string `yaml:"fzf-bind-kill"`
-	FZFPrompt               string `yaml:"fzf-prompt"`
-	FZFOpts                 string `yaml:"fzf-opts"`
-	ZoxideOpts              string `yaml:"zoxide-opts"`
-	ProgramWhitelist        string `yaml:"program-whitelist"`
-	NvimCustomCommand       string `yaml:"nvim-custom-command"`
-	SelectFirst             bool   `yaml:"select-first"`
-	CloseOnNew              bool   `yaml:"close-on-new"`
-	ActiveSessionPrefix     string `yaml:"active-session-prefix"`
-	IgnoreHome              bool   `yaml:"ignore-home"`
+	AutoSaveIntervalMinutes int                `yaml:"auto-save-interval-minutes"`
+	FZFBindNew              string             `yaml:"fzf-bind-new"`
+	FZFBindDelete           string             `yaml:"fzf-bind-delete"`
+	FZFBindInteractive      string             `yaml:"fzf-bind-interactive"`
+	FZFBindSave             string             `yaml:"fzf-bind-save"`
+	FZFBindKill             string             `yaml:"fzf-bind-kill"`
+	FZFBindWorktree         string             `yaml:"fzf-bind-worktree"`
+	FZFBindPin              string             `yaml:"fzf-bind-pin"`
+	FZFBindAutostart        string             `yaml:"fzf-bind-autostart"`
+	FZFBindFreeze           string             `yaml:"fzf-bind-freeze"`
+	FZFPrompt               string             `yaml:"fzf-prompt"`
+	FZFOpts                 string             `yaml:"fzf-opts"`
+	ZoxideOpts              string             `yaml:"zoxide-opts"`
+	ProgramWhitelist        string             `yaml:"program-whitelist"`
+	NvimCustomCommand       string             `yaml:"nvim-custom-command"`
+	SelectFirst             bool               `yaml:"select-first"`
+	CloseOnNew              bool               `yaml:"close-on-new"`
+	ActiveSessionPrefix     string             `yaml:"active-session-prefix"`
+	IgnoreHome              bool               `yaml:"ignore-home"`
+	IncludeNames            string             `yaml:"include-names"`
+	ExcludeNames            string             `yaml:"exclude-names"`
+	IncludePaths            string             `yaml:"include-paths"`
+	ExcludePaths            string             `yaml:"exclude-paths"`
+	Picker                  string             `yaml:"picker"`
+	PickerPreview           bool               `yaml:"picker-preview"`
+	SessionOrder            string             `yaml:"session-order"`
+	ProjectRoots            string             `yaml:"project-roots"`
+	ProjectMaxDepth         int                `yaml:"project-max-depth"`
+	ProjectIgnore           string             `yaml:"project-ignore"`
+	ProjectMarkers          string             `yaml:"project-markers"`
+	ProjectCacheMinutes     int                `yaml:"project-cache-minutes"`
+	GitAwareSessions        bool               `yaml:"git-aware-sessions"`
+	GitSessionTemplate      string             `yaml:"git-session-template"`
+	GitWorktreePath         string             `yaml:"git-worktree-path"`
+	Templates               []session.Template `yaml:"templates"`
+	PathRoots               map[string]string  `yaml:"path-roots"`
+	StaleSessionPrefix      string             `yaml:"stale-session-prefix"`
+	AutoPrune               bool               `yaml:"auto-prune"`
+	WaitForShell            bool               `yaml:"wait-for-shell"`
+	BootRestore             bool               `yaml:"boot-restore"`
+	RestoreSessions         string             `yaml:"restore-sessions"`
+	SessionStore            string             `yaml:"session-store"`
+	IncludeDir              string             `yaml:"include-dir"`
+	Actions                 []Action           `yaml:"actions"`
+	PickerSources           string             `yaml:"picker-sources"`
+	Hooks                   map[string]string  `yaml:"hooks"`
+	HookTimeoutSeconds      int                `yaml:"hook-timeout-seconds"`
 }
 
+// SourceNames are the sources picker-sources can list besides the running
+// and saved sessions.
+var SourceNames = []string{"worktrees", "templates", "projects", "zoxide", "plugins"}
+
+// HookEvents are the session lifecycle events hooks can be configured for,
+// as pre-<event> and post-<event>.
+var HookEvents = []string{"create", "restore", "switch", "save", "kill"}
+
+// Action is a user defined picker action running a shell command. The
+// command may use {name} and {path} of the selected session.
+type Action struct {
+	Key     string `yaml:"key"`
+	Label   string `yaml:"label"`
+	Command string `yaml:"command"`
+	// Reload returns to the picker once the command finished.
+	Reload bool `yaml:"reload,omitempty"`
+	// Plugin and Args are set for actions registered by a plugin, which
+	// run the plugin instead of Command.
+	Plugin string   `yaml:"-"`
+	Args   []string `yaml:"-"`
+}
+
+// PathEnv names the environment variable overriding the config file.
+const PathEnv = "GO_TMS_CONFIG"
+
+// getConfigPath returns $GO_TMS_CONFIG or the file in the XDG config
+// directory. A config left in ~/.config is still used when XDG_CONFIG_HOME
+// points elsewhere and has none.
 func getConfigPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
+	if path := os.Getenv(PathEnv); path != "" {
+		return path, nil
+	}
+	configHome, err := xdg.ConfigHome()
 	if err != nil {
 		return "", err
 	}
+	xdgConfigPath := filepath.Join(configHome, configPath)
+	if _, err := os.Stat(xdgConfigPath); err == nil {
+		return xdgConfigPath, nil
+	}
 
-	xdgConfigPath := filepath.Join(homeDir, ".config", configPath)
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	legacyConfigPath := filepath.Join(homeDir, ".config", configPath)
+	if _, err := os.Stat(legacyConfigPath); err == nil {
+		return legacyConfigPath, nil
+	}
 	return xdgConfigPath, nil
 }
 
-func LoadConfig() (Config, error) {
-	config := Config{
+// Default returns the built-in configuration.
+func Default() Config {
+	return Config{
 		AutoSaveIntervalMinutes: 10,
 		FZFBindNew:              "ctrl-n",
 		FZFBindDelete:           "ctrl-d",
 		FZFBindInteractive:      "ctrl-i",
 		FZFBindSave:             "ctrl-s",
 		FZFBindKill:             "ctrl-k",
+		FZFBindWorktree:         "ctrl-t",
+		FZFBindPin:              "alt-p",
+		FZFBindAutostart:        "alt-a",
+		FZFBindFreeze:           "alt-f",
 		FZFPrompt:               "Sessions> ",
 		FZFOpts:                 "--no-sort --reverse",
 		ZoxideOpts:              "--layout=reverse --style=full --border=bold --border=rounded --margin=3%",
@@ -54,26 +134,39 @@ func LoadConfig() (Config, error) {
 		CloseOnNew:              true,
 		ActiveSessionPrefix:     " ",
 		IgnoreHome:              false,
+		IncludeNames:            "",
+		ExcludeNames:            "",
+		IncludePaths:            "",
+		ExcludePaths:            "/dev/null,/tmp",
+		Picker:                  "auto",
+		PickerPreview:           true,
+		SessionOrder:            "frecency",
+		ProjectRoots:            "",
+		ProjectMaxDepth:         3,
+		ProjectIgnore:           "node_modules,vendor,target,.cache",
+		ProjectMarkers:          ".git,go.mod,package.json",
+		ProjectCacheMinutes:     10,
+		GitAwareSessions:        true,
+		GitSessionTemplate:      "{repo}/{worktree}",
+		GitWorktreePath:         "{root}/../{repo}-{branch}",
+		StaleSessionPrefix:      "! ",
+		AutoPrune:               false,
+		WaitForShell:            false,
+		BootRestore:             false,
+		PickerSources:           "worktrees,plugins",
+		HookTimeoutSeconds:      10,
+		RestoreSessions:         "",
+		SessionStore:            "",
+		IncludeDir:              "config.d",
 	}
+}
 
-	configFilePath, err := getConfigPath()
-	if err != nil {
-		return config, err
-	}
-
-	configFile, err := os.Open(configFilePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return config, nil
-		}
-		return config, err
-	}
-	defer configFile.Close()
-
-	yamlDecoder := yaml.NewDecoder(configFile)
-	if err = yamlDecoder.Decode(&config); err != nil {
-		return config, err
-	}
+func LoadConfig() (Config, error) {
+	config, _, err := LoadConfigWithOrigins()
+	return config, err
+}
 
-	return config, nil
+// Path returns the location of the user's config file.
+func Path() (string, error) {
+	return getConfigPath()
 }
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	cfg := Default()
+	if problems := Validate(&cfg, nil); len(problems) != 0 {
+		t.Errorf("expected the defaults to be valid, got %v", problems)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	data := `
+auto-save-intervall-minutes: 5
+auto-save-interval-minutes: 0
+fzf-bind-kill: ctrl-n
+fzf-bind-save: ""
+project-max-depth: deep
+session-order: random
+program-whitelist: nvim
+actions:
+  - key: alt-p
+    command: git pull
+  - label: lazygit
+    comand: lazygit
+hooks:
+  pre-open: x
+  post-save: y
+  after-kill: z
+templates:
+  - name: dev
+    windows:
+      - name: editor
+        pannes:
+          - nvim
+`
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Default()
+	origins := make(map[string]Origin)
+	problems := decode(doc.Content[0], "config.yaml", &cfg, origins)
+	problems = append(problems, Validate(&cfg, origins)...)
+
+	expected := []string{
+		"config.yaml:2: auto-save-intervall-minutes: unknown key (did you mean auto-save-interval-minutes?)",
+		"config.yaml:13: actions: unknown key actions[2].comand (did you mean command?)",
+		"config.yaml:22: templates: unknown key templates[1].windows[1].pannes (did you mean panes?)",
+		"config.yaml:6: project-max-depth: cannot unmarshal",
+		"config.yaml:3: auto-save-interval-minutes: must be at least 1, got 0",
+		`config.yaml:7: session-order: must be one of frecency, recency, alphabetical, got "random"`,
+		"config.yaml:5: fzf-bind-save: must not be empty",
+		"config.yaml:4: fzf-bind-kill: ctrl-n is already bound by fzf-bind-new",
+		"config.yaml:9: actions: entry 1: alt-p is already bound by fzf-bind-pin",
+		"config.yaml:9: actions: entry 2 needs a key and a command",
+		`config.yaml:14: hooks: unknown hook "after-kill"`,
+		`config.yaml:14: hooks: unknown hook "pre-open"`,
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %d: %v", len(expected), len(problems), problems)
+	}
+	for i, p := range problems {
+		if !strings.HasPrefix(p.String(), expected[i]) {
+			t.Errorf("problem %d = %q, expected %q", i, p.String(), expected[i])
+		}
+	}
+
+	resetInvalid(&cfg, problems)
+	if cfg.AutoSaveIntervalMinutes != 10 || cfg.FZFBindKill != "ctrl-k" || cfg.SessionOrder != "frecency" {
+		t.Errorf("expected invalid values to fall back to defaults, got %+v", cfg)
+	}
+	if cfg.ProgramWhitelist != "nvim" {
+		t.Errorf("expected valid values to be kept, got %q", cfg.ProgramWhitelist)
+	}
+}
+
+func TestLayers(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+	t.Setenv("XDG_CONFIG_DIRS", filepath.Join(home, "system"))
+	t.Setenv(PathEnv, "")
+	files := map[string]string{
+		"system/go-tms/config.yaml":          "picker: fzf\nfzf-prompt: 'S> '\nselect-first: false\n",
+		"config/go-tms/config.yaml":          "picker: builtin\n",
+		"config/go-tms/config.d/a.yaml":      "project-max-depth: 5\ntemplates:\n  - name: api\n    file: api.yml\n",
+		"config/go-tms/config.d/b.yaml":      "project-max-depth: 6\n",
+		"config/go-tms/config.d/ignored.txt": "picker: auto\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(home, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GO_TMS_SELECT_FIRST", "true")
+	t.Setenv("GO_TMS_FZF_OPTS", "--height 40%")
+	t.Setenv("GO_TMS_FZF_PROMPT", "> ")
+	SetOverrides([]string{"fzf-opts=--reverse", "project-cache-minutes=2", "nvim-custom-command=Run: nvim", "path-roots={WORK: /work}"})
+	defer SetOverrides(nil)
+
+	cfg, origins, err := LoadConfigWithOrigins()
+	if err != nil {
+		t.Fatalf("LoadConfigWithOrigins failed: %v", err)
+	}
+	expected := []struct {
+		key    string
+		value  any
+		origin string
+	}{
+		{"picker", cfg.Picker, "config/go-tms/config.yaml:1"},
+		{"fzf-prompt", cfg.FZFPrompt, "$GO_TMS_FZF_PROMPT"},
+		{"project-max-depth", cfg.ProjectMaxDepth, "config/go-tms/config.d/b.yaml:1"},
+		{"select-first", cfg.SelectFirst, "$GO_TMS_SELECT_FIRST"},
+		{"fzf-opts", cfg.FZFOpts, "-set fzf-opts=--reverse"},
+		{"project-cache-minutes", cfg.ProjectCacheMinutes, "-set project-cache-minutes=2"},
+		{"close-on-new", cfg.CloseOnNew, OriginDefault},
+		{"nvim-custom-command", cfg.NvimCustomCommand, "-set nvim-custom-command=Run: nvim"},
+		{"path-roots", cfg.PathRoots["WORK"], "-set path-roots={WORK: /work}"},
+		{"templates", cfg.Templates[0].File, "config/go-tms/config.d/a.yaml:2"},
+	}
+	values := []any{"builtin", "> ", 6, true, "--reverse", 2, true, "Run: nvim", "/work", filepath.Join(home, "config/go-tms/config.d/api.yml")}
+	for i, e := range expected {
+		if e.value != values[i] {
+			t.Errorf("%s = %v, expected %v", e.key, e.value, values[i])
+		}
+		if got := strings.TrimPrefix(origins[e.key].String(), home+"/"); got != e.origin {
+			t.Errorf("origin of %s = %s, expected %s", e.key, got, e.origin)
+		}
+	}
+
+	t.Setenv("GO_TMS_NO_SUCH_KEY", "1")
+	if _, _, err := LoadConfigWithOrigins(); err == nil || !strings.Contains(err.Error(), "GO_TMS_NO_SUCH_KEY") {
+		t.Errorf("expected an unknown variable to be reported, got %v", err)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	for _, key := range Keys() {
+		if descriptions[key] == "" {
+			t.Errorf("config key %s has no description", key)
+		}
+	}
+	for key := range descriptions {
+		if _, ok := field(&Config{}, key); !ok {
+			t.Errorf("description of unknown config key %s", key)
+		}
+	}
+
+	data, err := Document()
+	if err != nil {
+		t.Fatalf("Document failed: %v", err)
+	}
+	doc := string(data)
+	for _, line := range []string{"\n# auto-save-interval-minutes: 10\n", "\n# picker: auto\n", "\n# templates: []\n"} {
+		if !strings.Contains(doc, line) {
+			t.Errorf("expected the document to contain %q", line)
+		}
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Errorf("expected the document to be valid YAML: %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	cfg := Default()
+	cfg.Picker = "builtin"
+	origins := map[string]Origin{
+		"picker":       {Source: "config.yaml", Line: 2},
+		"select-first": {Source: "$GO_TMS_SELECT_FIRST"},
+		"fzf-opts":     {Source: OriginDefault},
+	}
+	diff, err := Diff(&cfg, origins)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	expected := "# $GO_TMS_SELECT_FIRST, same as default\n  select-first: true\n" +
+		"# config.yaml:2\n- picker: auto\n+ picker: builtin\n"
+	if diff != expected {
+		t.Errorf("Diff() = %q, expected %q", diff, expected)
+	}
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// descriptions document every config key for config init. A test keeps
+// them in sync with the Config struct.
+var descriptions = map[string]string{
+	"auto-save-interval-minutes": "Minutes between automatic saves of the running sessions by the daemon.",
+	"fzf-bind-new":               "Picker key creating a session in the current directory.",
+	"fzf-bind-delete":            "Picker key deleting the selected session from tmux and the store.",
+	"fzf-bind-interactive":       "Picker key opening the project directory search.",
+	"fzf-bind-save":              "Picker key saving all running sessions.",
+	"fzf-bind-kill":              "Picker key killing the selected session without deleting it.",
+	"fzf-bind-worktree":          "Picker key creating a git worktree for the selected session.",
+	"fzf-bind-pin":               "Picker key toggling whether the selected session is pinned.",
+	"fzf-bind-autostart":         "Picker key toggling whether the selected session starts on boot.",
+	"fzf-bind-freeze":            "Picker key toggling whether the selected session's layout is frozen.",
+	"fzf-prompt":                 "Prompt of the session picker.",
+	"fzf-opts":                   "Extra options passed to fzf.",
+	"zoxide-opts":                "Extra fzf options for the project directory search.",
+	"program-whitelist":          "Comma separated programs relaunched in their pane on restore.",
+	"nvim-custom-command":        "Command relaunching nvim instead of plain nvim, such as nvim -S.",
+	"select-first":               "Select the first window after restoring a session.",
+	"close-on-new":               "Close the current window after creating a new session from it.",
+	"active-session-prefix":      "Marker of running sessions in the fzf picker.",
+	"ignore-home":                "Do not save sessions started in the home directory.",
+	"include-names":              "Comma separated session name globs, or re: regexes, saved even when excluded.",
+	"exclude-names":              "Comma separated session name globs, or re: regexes, never saved.",
+	"include-paths":              "Comma separated session path globs saved even when excluded. dir/** matches a tree.",
+	"exclude-paths":              "Comma separated session path globs never saved. dir/** matches a tree.",
+	"picker":                     "Session picker: auto (fzf when installed), fzf or builtin.",
+	"picker-preview":             "Show a preview of the session's windows in the builtin picker.",
+	"session-order":              "Order of the picker: frecency, recency or alphabetical.",
+	"project-roots":              "Comma separated directories scanned for projects.",
+	"project-max-depth":          "How deep below the project roots to look for projects.",
+	"project-ignore":             "Comma separated directory names never scanned.",
+	"project-markers":            "Comma separated files or directories marking a project.",
+	"project-cache-minutes":      "Minutes the project scan is cached for.",
+	"git-aware-sessions":         "Name sessions after git repositories and list their worktrees.",
+	"git-session-template":       "Name of sessions in git repositories, from {repo}, {worktree} and {branch}.",
+	"git-worktree-path":          "Directory of new worktrees, from {root}, {repo} and {branch}.",
+	"templates":                  "Session templates applied to new sessions in matching directories, e.g.\ntemplates:\n  - name: go\n    match: ~/go/*\n    windows:\n      - name: editor\n        panes: [nvim]",
+	"path-roots":                 "Variables session paths are stored relative to, e.g.\npath-roots:\n  PROJECTS: ~/projects",
+	"stale-session-prefix":       "Marker of saved sessions whose directory is gone in the fzf picker.",
+	"auto-prune":                 "Remove saved sessions whose directory is gone automatically.",
+	"wait-for-shell":             "Wait for the shell of every pane to start before sending commands on restore.",
+	"boot-restore":               "Restore the sessions selected by restore-sessions on boot.",
+	"restore-sessions":           "Comma separated session name globs restored by boot-restore and restore -all. Empty selects all.",
+	"session-store":              "Session store file. Empty uses $XDG_STATE_HOME/go-tms/sessions.yaml.",
+	"include-dir":                "Directory of *.yaml fragments loaded after this file, relative to it.",
+	"picker-sources":             "Comma separated sources the switcher lists after the running and saved sessions, in order: worktrees (with git-aware-sessions), templates (directories matched by templates), projects (found under project-roots), zoxide and plugins. Entries of a directory that is already listed are skipped.",
+	"hooks":                      "Shell commands run before (pre-) and after (post-) sessions are created, restored, switched to, saved or killed. They get TMS_HOOK, TMS_SESSION_NAME and TMS_SESSION_PATH in the environment; a failing pre- hook cancels the operation, e.g.\nhooks:\n  post-restore: docker compose up -d\n  pre-kill: docker compose down",
+	"hook-timeout-seconds":       "Seconds a hook may run before it is stopped and counted as failed.",
+	"actions":                    "Picker actions running a shell command with the selected session's {name} and {path}, e.g.\nactions:\n  - key: ctrl-g\n    label: lazygit\n    command: tmux display-popup -d {path} -E lazygit\n  - key: alt-u\n    label: pull\n    command: git -C {path} pull\n    reload: true",
+}
+
+// renderKey returns the YAML of a single key of cfg.
+func renderKey(cfg *Config, key string) (string, error) {
+	value, ok := field(cfg, key)
+	if !ok {
+		return "", fmt.Errorf("unknown key: %s", key)
+	}
+	var b strings.Builder
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string]any{key: value.Interface()}); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+func comment(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("# "+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Document returns a config file listing every key with its description
+// and default value, commented out so future defaults still apply.
+func Document() ([]byte, error) {
+	defaults := Default()
+	var b strings.Builder
+	b.WriteString("# go-tms configuration. Uncomment a key to change its default.\n")
+	b.WriteString("# Keys can also be set with " + EnvPrefix + "* variables or -set key=value.\n")
+	for _, key := range Keys() {
+		rendered, err := renderKey(&defaults, key)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "\n%s\n%s\n", comment(descriptions[key]), comment(rendered))
+	}
+	return []byte(b.String()), nil
+}
+
+// Diff lists the keys set by any config layer, with their default and
+// effective values and where they were set.
+func Diff(cfg *Config, origins map[string]Origin) (string, error) {
+	defaults := Default()
+	var b strings.Builder
+	for _, key := range Keys() {
+		origin := origins[key]
+		if origin.Source == OriginDefault || origin.Source == "" {
+			continue
+		}
+		before, err := renderKey(&defaults, key)
+		if err != nil {
+			return "", err
+		}
+		after, err := renderKey(cfg, key)
+		if err != nil {
+			return "", err
+		}
+		value, _ := field(cfg, key)
+		def, _ := field(&defaults, key)
+		if reflect.DeepEqual(value.Interface(), def.Interface()) {
+			fmt.Fprintf(&b, "# %s, same as default\n%s\n", origin, prefixLines("  ", after))
+			continue
+		}
+		fmt.Fprintf(&b, "# %s\n%s\n%s\n", origin, prefixLines("- ", before), prefixLines("+ ", after))
+	}
+	return b.String(), nil
+}
+
+func prefixLines(prefix string, text string) string {
+	return prefix + strings.ReplaceAll(text, "\n", "\n"+prefix)
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/swit33/go-tms/pkg/session"
+	"gopkg.in/yaml.v3"
+)
+
+// OriginDefault marks values that were not set by any layer.
+const OriginDefault = "default"
+
+// EnvPrefix starts the environment variables overriding single keys, such
+// as GO_TMS_FZF_OPTS for fzf-opts.
+const EnvPrefix = "GO_TMS_"
+
+// overrides are key=value settings given on the command line.
+var overrides []string
+
+// SetOverrides sets key=value pairs that take precedence over every other
+// layer.
+func SetOverrides(o []string) {
+	overrides = o
+}
+
+// EnvName returns the environment variable overriding key.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// systemPaths returns the system wide config files, lowest precedence
+// first.
+func systemPaths() []string {
+	dirs := os.Getenv("XDG_CONFIG_DIRS")
+	if dirs == "" {
+		dirs = "/etc/xdg"
+	}
+	var paths []string
+	for _, dir := range filepath.SplitList(dirs) {
+		if filepath.IsAbs(dir) {
+			paths = append(paths, filepath.Join(dir, configPath))
+		}
+	}
+	slices.Reverse(paths)
+	return paths
+}
+
+// includePaths returns the fragments of the include directory in lexical
+// order. A relative directory is resolved against the user's config file.
+func includePaths(dir string, userPath string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if expanded, err := session.ExpandHome(dir); err == nil {
+		dir = expanded
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(userPath), dir)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// LoadConfigWithOrigins layers the configuration from the defaults, the
+// system files, the user's file, the fragments of its include directory,
+// GO_TMS_* environment variables and command line overrides, and reports
+// where every key was set.
+func LoadConfigWithOrigins() (Config, map[string]Origin, error) {
+	config := Default()
+	origins := make(map[string]Origin)
+	for _, key := range Keys() {
+		origins[key] = Origin{Source: OriginDefault}
+	}
+	var problems []Problem
+
+	userPath, err := getConfigPath()
+	if err != nil {
+		return config, origins, err
+	}
+	for _, path := range systemPaths() {
+		if err := loadFile(path, &config, origins, &problems); err != nil {
+			return Default(), origins, err
+		}
+	}
+	if err := loadFile(userPath, &config, origins, &problems); err != nil {
+		return Default(), origins, err
+	}
+	fragments, err := includePaths(config.IncludeDir, userPath)
+	if err != nil {
+		return Default(), origins, err
+	}
+	for _, path := range fragments {
+		if err := loadFile(path, &config, origins, &problems); err != nil {
+			return Default(), origins, err
+		}
+	}
+
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, EnvPrefix) || name == PathEnv {
+			continue
+		}
+		key := envKey(name)
+		if key == "" {
+			problems = append(problems, Problem{Key: name, Origin: Origin{Source: "environment"}, Message: "unknown variable"})
+			continue
+		}
+		problems = append(problems, decode(setting(key, value), "$"+name, &config, origins)...)
+	}
+	for _, o := range overrides {
+		key, value, ok := strings.Cut(o, "=")
+		origin := Origin{Source: "-set " + o}
+		if !ok {
+			problems = append(problems, Problem{Key: o, Origin: origin, Message: "expected key=value"})
+			continue
+		}
+		problems = append(problems, decode(setting(key, value), origin.Source, &config, origins)...)
+	}
+
+	problems = append(problems, Validate(&config, origins)...)
+	if len(problems) > 0 {
+		resetInvalid(&config, problems)
+		return config, origins, &ValidationError{Problems: problems}
+	}
+	return config, origins, nil
+}
+
+// loadFile decodes a config file on top of config if it exists.
+func loadFile(path string, config *Config, origins map[string]Origin, problems *[]Problem) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	*problems = append(*problems, decode(doc.Content[0], path, config, origins)...)
+	for i := range config.Templates {
+		config.Templates[i].ResolveFile(filepath.Dir(path))
+	}
+	return nil
+}
+
+func envKey(name string) string {
+	for _, key := range Keys() {
+		if EnvName(key) == name {
+			return key
+		}
+	}
+	return ""
+}
+
+// setting builds a single key mapping. Values of string keys are taken as
+// they are; others are read as YAML so numbers, booleans and flow
+// collections keep their type.
+func setting(key string, value string) *yaml.Node {
+	valueNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	if f, ok := field(&Config{}, key); !ok || f.Kind() != reflect.String {
+		var doc yaml.Node
+		if err := yaml.Unmarshal([]byte(value), &doc); err == nil && len(doc.Content) > 0 {
+			valueNode = doc.Content[0]
+		}
+	}
+	return &yaml.Node{
+		Kind:    yaml.MappingNode,
+		Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: key}, valueNode},
+	}
+}
+
+// Marshal renders the configuration as YAML in declaration order. With
+// origins, every key is annotated with where it was set.
+func Marshal(cfg *Config, origins map[string]Origin) ([]byte, error) {
+	var root yaml.Node
+	if err := root.Encode(cfg); err != nil {
+		return nil, err
+	}
+	if origins != nil {
+		for i := 0; i < len(root.Content); i += 2 {
+			key, value := root.Content[i], root.Content[i+1]
+			comment := origins[key.Value].String()
+			// Empty collections are written inline and only keep a
+			// comment on the value.
+			if value.Kind != yaml.ScalarNode && len(value.Content) == 0 {
+				value.LineComment = comment
+			} else {
+				key.LineComment = comment
+			}
+		}
+	}
+	var b strings.Builder
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&root); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+// Sources returns every file the configuration is read from, including
+// ones that do not exist yet, for watching them.
+func Sources(cfg *Config) ([]string, error) {
+	userPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	fragments, err := includePaths(cfg.IncludeDir, userPath)
+	if err != nil {
+		return nil, err
+	}
+	sources := append(systemPaths(), userPath)
+	return append(sources, fragments...), nil
+}
@@ -0,0 +1,348 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/swit33/go-tms/pkg/session"
+	"gopkg.in/yaml.v3"
+)
+
+// Origin records where a config value was set.
+type Origin struct {
+	// Source is a file path, an environment variable or a flag.
+	Source string
+	Line   int
+}
+
+func (o Origin) String() string {
+	if o.Line > 0 {
+		return fmt.Sprintf("%s:%d", o.Source, o.Line)
+	}
+	return o.Source
+}
+
+// Problem is an invalid config setting.
+type Problem struct {
+	Key     string
+	Origin  Origin
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Origin.Source == "" {
+		return fmt.Sprintf("%s: %s", p.Key, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Origin, p.Key, p.Message)
+}
+
+// ValidationError lists every problem found in the configuration. Invalid
+// values are replaced by their defaults.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := []string{"invalid config:"}
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Keys returns the yaml keys of the Config struct in declaration order.
+func Keys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, 0, t.NumField())
+	for i := range t.NumField() {
+		keys = append(keys, yamlKey(t.Field(i)))
+	}
+	return keys
+}
+
+func yamlKey(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	return name
+}
+
+// field returns the struct field of cfg stored under key.
+func field(cfg *Config, key string) (reflect.Value, bool) {
+	v := reflect.ValueOf(cfg).Elem()
+	for i := range v.NumField() {
+		if yamlKey(v.Type().Field(i)) == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// decode reads a YAML mapping into cfg, recording the origin of every key
+// and returning unknown keys and values of the wrong type as problems.
+func decode(root *yaml.Node, source string, cfg *Config, origins map[string]Origin) []Problem {
+	var problems []Problem
+	if root.Kind != yaml.MappingNode {
+		origin := Origin{Source: source, Line: root.Line}
+		return []Problem{{Key: "config", Origin: origin, Message: "expected a mapping of config keys"}}
+	}
+
+	lines := make(map[int]string)
+	known := Keys()
+	for i := 0; i < len(root.Content); i += 2 {
+		key := root.Content[i]
+		origin := Origin{Source: source, Line: key.Line}
+		lines[key.Line] = key.Value
+		if !slices.Contains(known, key.Value) {
+			message := "unknown key"
+			if suggestion := closestKey(key.Value, known); suggestion != "" {
+				message += fmt.Sprintf(" (did you mean %s?)", suggestion)
+			}
+			problems = append(problems, Problem{Key: key.Value, Origin: origin, Message: message})
+			continue
+		}
+		origins[key.Value] = origin
+		if f, ok := field(cfg, key.Value); ok {
+			problems = append(problems, unknownNested(root.Content[i+1], f.Type(), key.Value, key.Value, source)...)
+		}
+	}
+
+	if err := root.Decode(cfg); err != nil {
+		typeErr, ok := err.(*yaml.TypeError)
+		if !ok {
+			return append(problems, Problem{Key: "config", Origin: Origin{Source: source}, Message: err.Error()})
+		}
+		for _, msg := range typeErr.Errors {
+			problems = append(problems, typeProblem(msg, source, lines))
+		}
+	}
+	return problems
+}
+
+// structKeys returns the yaml keys of a struct type, including those of
+// inlined structs.
+func structKeys(t reflect.Type) map[string]reflect.Type {
+	keys := make(map[string]reflect.Type)
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		switch {
+		case name == "-" || !f.IsExported():
+		case opts == "inline":
+			maps.Copy(keys, structKeys(f.Type))
+		case name == "":
+			keys[strings.ToLower(f.Name)] = f.Type
+		default:
+			keys[name] = f.Type
+		}
+	}
+	return keys
+}
+
+// unknownNested reports keys of nested mappings that do not belong to the
+// struct they are decoded into. Problems are attributed to the top level key
+// so its value is reset.
+func unknownNested(node *yaml.Node, t reflect.Type, key string, path string, source string) []Problem {
+	var problems []Problem
+	switch t.Kind() {
+	case reflect.Pointer:
+		return unknownNested(node, t.Elem(), key, path, source)
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range node.Content {
+			problems = append(problems, unknownNested(item, t.Elem(), key, fmt.Sprintf("%s[%d]", path, i+1), source)...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i < len(node.Content); i += 2 {
+			problems = append(problems, unknownNested(node.Content[i+1], t.Elem(), key, path+"."+node.Content[i].Value, source)...)
+		}
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := structKeys(t)
+		known := slices.Sorted(maps.Keys(fields))
+		for i := 0; i < len(node.Content); i += 2 {
+			name := node.Content[i]
+			fieldType, ok := fields[name.Value]
+			if !ok {
+				message := fmt.Sprintf("unknown key %s.%s", path, name.Value)
+				if suggestion := closestKey(name.Value, known); suggestion != "" {
+					message += fmt.Sprintf(" (did you mean %s?)", suggestion)
+				}
+				problems = append(problems, Problem{Key: key, Origin: Origin{Source: source, Line: name.Line}, Message: message})
+				continue
+			}
+			problems = append(problems, unknownNested(node.Content[i+1], fieldType, key, path+"."+name.Value, source)...)
+		}
+	}
+	return problems
+}
+
+// typeProblem attributes a yaml type error such as "line 3: cannot
+// unmarshal ..." to the key on that line.
+func typeProblem(msg string, source string, lines map[int]string) Problem {
+	var line int
+	if rest, ok := strings.CutPrefix(msg, "line "); ok {
+		number, detail, _ := strings.Cut(rest, ": ")
+		line, _ = strconv.Atoi(number)
+		msg = detail
+	}
+	key, ok := lines[line]
+	if !ok {
+		key = "config"
+	}
+	return Problem{Key: key, Origin: Origin{Source: source, Line: line}, Message: msg}
+}
+
+// closestKey suggests the known key a misspelled one was meant to be.
+func closestKey(key string, known []string) string {
+	best, bestDistance := "", 4
+	for _, k := range known {
+		if d := distance(key, k); d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+var pathRootName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Validate checks the values of cfg. origins tells where each key was set
+// and may be nil.
+func Validate(cfg *Config, origins map[string]Origin) []Problem {
+	var problems []Problem
+	report := func(key string, format string, args ...any) {
+		problems = append(problems, Problem{Key: key, Origin: origins[key], Message: fmt.Sprintf(format, args...)})
+	}
+
+	if cfg.AutoSaveIntervalMinutes < 1 {
+		report("auto-save-interval-minutes", "must be at least 1, got %d", cfg.AutoSaveIntervalMinutes)
+	}
+	if cfg.HookTimeoutSeconds < 1 {
+		report("hook-timeout-seconds", "must be at least 1, got %d", cfg.HookTimeoutSeconds)
+	}
+	if cfg.ProjectMaxDepth < 0 {
+		report("project-max-depth", "must not be negative, got %d", cfg.ProjectMaxDepth)
+	}
+	if cfg.ProjectCacheMinutes < 0 {
+		report("project-cache-minutes", "must not be negative, got %d", cfg.ProjectCacheMinutes)
+	}
+	if pickers := []string{"auto", "fzf", "builtin"}; !slices.Contains(pickers, cfg.Picker) {
+		report("picker", "must be one of %s, got %q", strings.Join(pickers, ", "), cfg.Picker)
+	}
+	orders := []string{session.OrderFrecency, session.OrderRecency, session.OrderAlphabetical}
+	if !slices.Contains(orders, cfg.SessionOrder) {
+		report("session-order", "must be one of %s, got %q", strings.Join(orders, ", "), cfg.SessionOrder)
+	}
+
+	for name := range strings.SplitSeq(cfg.PickerSources, ",") {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(SourceNames, name) {
+			report("picker-sources", "unknown source %q, expected any of %s", name, strings.Join(SourceNames, ", "))
+		}
+	}
+
+	boundBy := make(map[string]string)
+	for _, key := range Keys() {
+		if !strings.HasPrefix(key, "fzf-bind-") {
+			continue
+		}
+		value, _ := field(cfg, key)
+		bind := value.String()
+		if bind == "" {
+			report(key, "must not be empty")
+			continue
+		}
+		if other, ok := boundBy[bind]; ok {
+			report(key, "%s is already bound by %s", bind, other)
+			continue
+		}
+		boundBy[bind] = key
+	}
+	for i, a := range cfg.Actions {
+		if a.Key == "" || a.Command == "" {
+			report("actions", "entry %d needs a key and a command", i+1)
+			continue
+		}
+		if other, ok := boundBy[a.Key]; ok {
+			report("actions", "entry %d: %s is already bound by %s", i+1, a.Key, other)
+			continue
+		}
+		boundBy[a.Key] = fmt.Sprintf("actions entry %d", i+1)
+	}
+
+	for _, key := range []string{"include-names", "exclude-names"} {
+		value, _ := field(cfg, key)
+		if _, err := session.NewFilter(value.String(), "", "", ""); err != nil {
+			report(key, "%v", err)
+		}
+	}
+	for _, key := range []string{"include-paths", "exclude-paths"} {
+		value, _ := field(cfg, key)
+		if _, err := session.NewFilter("", "", value.String(), ""); err != nil {
+			report(key, "%v", err)
+		}
+	}
+
+	for i, t := range cfg.Templates {
+		if t.Name == "" {
+			report("templates", "entry %d has no name", i+1)
+		}
+		if _, err := filepath.Match(t.Match, ""); err != nil {
+			report("templates", "entry %d has an invalid match %q: %v", i+1, t.Match, err)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Hooks)) {
+		_, event, _ := strings.Cut(name, "-")
+		if !strings.HasPrefix(name, "pre-") && !strings.HasPrefix(name, "post-") || !slices.Contains(HookEvents, event) {
+			report("hooks", "unknown hook %q, expected pre- or post- followed by one of %s", name, strings.Join(HookEvents, ", "))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.PathRoots)) {
+		if !pathRootName.MatchString(strings.TrimPrefix(name, "$")) {
+			report("path-roots", "%q is not a valid variable name", name)
+		}
+	}
+	return problems
+}
+
+// resetInvalid restores the defaults of keys with problems.
+func resetInvalid(cfg *Config, problems []Problem) {
+	defaults := Default()
+	for _, p := range problems {
+		if value, ok := field(cfg, p.Key); ok {
+			def, _ := field(&defaults, p.Key)
+			value.Set(def)
+		}
+	}
+}
@@ -3,16 +3,22 @@ package daemon
 import (
 	"fmt"
 	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/hooks"
 	"github.com/swit33/go-tms/pkg/session"
 	"github.com/swit33/go-tms/pkg/tmux"
+	"github.com/swit33/go-tms/pkg/xdg"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 )
 
+// sendMsg shows a message in tmux. Tests replace it.
+var sendMsg = tmux.SendMsg
+
 func StartDaemon(cfg *config.Config) {
 	self, err := os.Executable()
 	if err != nil {
@@ -31,19 +37,47 @@ func RunDaemon(cfg *config.Config) {
 	}
 	defer releaseLockFile(file)
 	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
-	autosaveTicker := time.NewTicker(time.Duration(cfg.AutoSaveIntervalMinutes) * time.Minute)
+	autosaveInterval := time.Duration(cfg.AutoSaveIntervalMinutes) * time.Minute
+	autosaveTicker := time.NewTicker(autosaveInterval)
 	defer autosaveTicker.Stop()
 
 	monitorTicker := time.NewTicker(10 * time.Second)
 	defer monitorTicker.Stop()
 
+	configTicker := time.NewTicker(configPollInterval)
+	defer configTicker.Stop()
+	fingerprint := configFingerprint(cfg)
+
+	// The fingerprint is taken before reloading so an invalid config is not
+	// retried until it changes, and again after since the include directory
+	// may have moved.
+	reload := func() {
+		fingerprint = configFingerprint(cfg)
+		if !reloadConfig(cfg) {
+			return
+		}
+		fingerprint = configFingerprint(cfg)
+		if interval := time.Duration(cfg.AutoSaveIntervalMinutes) * time.Minute; interval != autosaveInterval {
+			autosaveInterval = interval
+			autosaveTicker.Reset(interval)
+		}
+	}
+
 	for {
 		select {
 		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				reload()
+				continue
+			}
 			fmt.Printf("Received signal '%s'. Shutting down daemon...\n", sig.String())
 			return
+		case <-configTicker.C:
+			if configFingerprint(cfg) != fingerprint {
+				reload()
+			}
 		case <-monitorTicker.C:
 			if !isTmuxServerRunning() {
 				fmt.Println("Tmux server is not running. Shutting down daemon.")
@@ -68,42 +102,95 @@ func isTmuxServerRunning() bool {
 func saveSessions(cfg *config.Config) {
 	tmuxSessions, err := tmux.ListSessions(cfg)
 	if err != nil {
-		tmux.SendMsg("Failed to list tmux sessions.")
+		sendMsg("Failed to list tmux sessions.")
 		return
 	}
 
 	savedSessions, err := session.LoadSessionsFromDisk()
 	if err != nil {
-		tmux.SendMsg("Failed to load sessions from disk.")
+		sendMsg("Failed to load sessions from disk.")
 		return
 	}
+	if cfg.AutoPrune {
+		savedSessions, _ = session.PruneStale(savedSessions)
+	}
 
 	combinedSessions, err := session.CombineSessions(tmuxSessions, savedSessions)
 	if err != nil {
-		tmux.SendMsg("Failed to combine sessions.")
+		sendMsg("Failed to combine sessions.")
 		return
 	}
 
+	if err := hooks.Pre(cfg, hooks.Save, hooks.Context{}); err != nil {
+		sendMsg(err.Error())
+		return
+	}
 	err = session.SaveSessionsToDisk(combinedSessions)
 	if err != nil {
-		tmux.SendMsg("Failed to save sessions to disk.")
-	} else {
-		tmux.SendMsg("Sessions saved successfully.")
+		sendMsg("Failed to save sessions to disk.")
+		return
+	}
+	if err := hooks.Post(cfg, hooks.Save, hooks.Context{}); err != nil {
+		sendMsg(err.Error())
+		return
+	}
+	sendMsg("Sessions saved successfully.")
+}
+
+// configPollInterval is how often the config files are checked for changes.
+const configPollInterval = 2 * time.Second
+
+// configFingerprint describes the size and modification time of every
+// config source, so edits, new files and removals change it.
+func configFingerprint(cfg *config.Config) string {
+	sources, err := config.Sources(cfg)
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, path := range sources {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(&b, "%s:missing\n", path)
+			continue
+		}
+		fmt.Fprintf(&b, "%s:%d:%d\n", path, info.Size(), info.ModTime().UnixNano())
 	}
+	return b.String()
+}
+
+// reloadConfig replaces cfg with the current configuration. An invalid
+// configuration is logged and the previous one is kept.
+func reloadConfig(cfg *config.Config) bool {
+	newCfg, err := config.LoadConfig()
+	if err == nil {
+		if err = session.SetPathRoots(newCfg.PathRoots); err != nil {
+			session.SetPathRoots(cfg.PathRoots)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Keeping the previous config: %v\n", err)
+		sendMsg("go-tms: config is invalid, keeping the previous one (see go-tms config check)")
+		return false
+	}
+	session.SetStorePath(session.ExpandPath(newCfg.SessionStore))
+	*cfg = newCfg
+	fmt.Println("Config reloaded.")
+	return true
 }
 
 const lockFileName = "go-tms.lock"
 
 func createLockFile() (*os.File, error) {
-	homePath, err := os.UserHomeDir()
+	runtimeDir, err := xdg.RuntimeDir()
 	if err != nil {
-		return nil, fmt.Errorf("could not get user config directory: %w", err)
+		return nil, fmt.Errorf("could not get runtime directory: %w", err)
 	}
 
-	lockFilePath := filepath.Join(homePath, ".tmux", "go-tms", lockFileName)
+	lockFilePath := filepath.Join(runtimeDir, "go-tms", lockFileName)
 
-	if err := os.MkdirAll(filepath.Dir(lockFilePath), 0755); err != nil {
-		return nil, fmt.Errorf("could not create config directory: %w", err)
+	if err := os.MkdirAll(filepath.Dir(lockFilePath), 0700); err != nil {
+		return nil, fmt.Errorf("could not create runtime directory: %w", err)
 	}
 
 	file, err := os.OpenFile(lockFilePath, os.O_CREATE|os.O_RDWR, 0644)
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/session"
+	"github.com/swit33/go-tms/pkg/tmux"
+)
+
+func TestReloadConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_DIRS", filepath.Join(home, "system"))
+	path := filepath.Join(home, "config.yaml")
+	t.Setenv(config.PathEnv, path)
+	var messages []string
+	sendMsg = func(msg string) { messages = append(messages, msg) }
+	t.Cleanup(func() {
+		sendMsg = tmux.SendMsg
+		session.SetStorePath("")
+		session.SetPathRoots(nil)
+	})
+
+	cfg := config.Default()
+	before := configFingerprint(&cfg)
+
+	if err := os.WriteFile(path, []byte("auto-save-interval-minutes: 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	after := configFingerprint(&cfg)
+	if before == after {
+		t.Errorf("expected creating the config file to change the fingerprint")
+	}
+	if !reloadConfig(&cfg) || cfg.AutoSaveIntervalMinutes != 3 {
+		t.Errorf("expected the new interval to be loaded, got %d", cfg.AutoSaveIntervalMinutes)
+	}
+	if len(messages) != 0 {
+		t.Errorf("expected no message for a valid config, got %q", messages)
+	}
+
+	// Make sure the modification time differs on coarse file systems.
+	time.Sleep(10 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("auto-save-interval-minutes: 0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if configFingerprint(&cfg) == after {
+		t.Errorf("expected editing the config file to change the fingerprint")
+	}
+	if reloadConfig(&cfg) || cfg.AutoSaveIntervalMinutes != 3 {
+		t.Errorf("expected an invalid config to keep the previous one, got %d", cfg.AutoSaveIntervalMinutes)
+	}
+	if len(messages) != 1 || !strings.Contains(messages[0], "config is invalid") {
+		t.Errorf("expected a message about the invalid config, got %q", messages)
+	}
+}
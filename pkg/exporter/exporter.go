@@ -0,0 +1,187 @@
+// Package exporter writes sessions as standalone shell scripts or as
+// tmuxinator and tmuxp project files.
+package exporter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/session"
+	"github.com/swit33/go-tms/pkg/tmux"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FormatScript     = "script"
+	FormatTmuxinator = "tmuxinator"
+	FormatTmuxp      = "tmuxp"
+)
+
+var Formats = []string{FormatScript, FormatTmuxinator, FormatTmuxp}
+
+// Export renders the session in the given format.
+func Export(s *session.Session, format string, cfg *config.Config) ([]byte, error) {
+	switch format {
+	case FormatScript:
+		return Script(s, cfg), nil
+	case FormatTmuxinator:
+		return marshal(tmuxinator(s, cfg))
+	case FormatTmuxp:
+		return marshal(tmuxp(s, cfg))
+	}
+	return nil, fmt.Errorf("unknown export format: %s (expected one of %s)", format, strings.Join(Formats, ", "))
+}
+
+func marshal(v any) ([]byte, error) {
+	var b strings.Builder
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+var safeShellWord = regexp.MustCompile(`^[a-zA-Z0-9_./:=@%+,-]+$`)
+
+// Quote quotes a word for POSIX shells.
+func Quote(word string) string {
+	if word != "" && safeShellWord.MatchString(word) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// Script returns a shell script issuing the same tmux commands as a restore
+// of the session, attaching to it when it already exists.
+func Script(s *session.Session, cfg *config.Config) []byte {
+	var b strings.Builder
+	name := Quote(s.Name)
+	fmt.Fprintf(&b, "#!/bin/sh\n")
+	fmt.Fprintf(&b, "# Recreates the tmux session %s. Generated by go-tms.\n", s.Name)
+	fmt.Fprintf(&b, "set -e\n\n")
+	fmt.Fprintf(&b, "if ! tmux has-session -t %s 2>/dev/null; then\n", name)
+	for _, step := range tmux.BuildRestorePlan(s, cfg) {
+		words := make([]string, 0, len(step.Args)+1)
+		words = append(words, "tmux")
+		for _, arg := range step.Args {
+			words = append(words, Quote(arg))
+		}
+		fmt.Fprintf(&b, "  %s\n", strings.Join(words, " "))
+	}
+	fmt.Fprintf(&b, "fi\n\n")
+	fmt.Fprintf(&b, "if [ -n \"$TMUX\" ]; then\n")
+	fmt.Fprintf(&b, "  tmux switch-client -t %s\n", name)
+	fmt.Fprintf(&b, "else\n")
+	fmt.Fprintf(&b, "  tmux attach-session -t %s\n", name)
+	fmt.Fprintf(&b, "fi\n")
+	return []byte(b.String())
+}
+
+// paneCommands prefixes the pane's commands with a cd when the pane does not
+// start in dir, since tmuxinator panes cannot have their own root.
+func paneCommands(p session.Pane, dir string, cfg *config.Config) []string {
+	commands := tmux.PaneCommands(p, cfg)
+	if p.CurrentPath != "" && p.CurrentPath != dir {
+		commands = append([]string{"cd " + Quote(p.CurrentPath)}, commands...)
+	}
+	return commands
+}
+
+func windowName(w session.Window, i int) string {
+	if w.Name != "" {
+		return w.Name
+	}
+	return fmt.Sprint(i + 1)
+}
+
+func windowRoot(s *session.Session, w session.Window) string {
+	if w.CurrentPath != "" {
+		return w.CurrentPath
+	}
+	if len(w.Panes) > 0 && w.Panes[0].CurrentPath != "" {
+		return w.Panes[0].CurrentPath
+	}
+	return s.CurrentPath
+}
+
+type tmuxinatorProject struct {
+	Name    string                        `yaml:"name"`
+	Root    string                        `yaml:"root"`
+	Windows []map[string]tmuxinatorWindow `yaml:"windows"`
+}
+
+type tmuxinatorWindow struct {
+	Layout string `yaml:"layout,omitempty"`
+	Root   string `yaml:"root,omitempty"`
+	Panes  []any  `yaml:"panes"`
+}
+
+func tmuxinator(s *session.Session, cfg *config.Config) tmuxinatorProject {
+	project := tmuxinatorProject{Name: s.Name, Root: s.CurrentPath}
+	for i, w := range s.Windows {
+		root := windowRoot(s, w)
+		window := tmuxinatorWindow{Layout: w.Layout}
+		if root != s.CurrentPath {
+			window.Root = root
+		}
+		for _, p := range w.Panes {
+			commands := paneCommands(p, root, cfg)
+			switch len(commands) {
+			case 0:
+				window.Panes = append(window.Panes, nil)
+			case 1:
+				window.Panes = append(window.Panes, commands[0])
+			default:
+				window.Panes = append(window.Panes, commands)
+			}
+		}
+		project.Windows = append(project.Windows, map[string]tmuxinatorWindow{windowName(w, i): window})
+	}
+	return project
+}
+
+type tmuxpProject struct {
+	SessionName    string            `yaml:"session_name"`
+	StartDirectory string            `yaml:"start_directory"`
+	Environment    map[string]string `yaml:"environment,omitempty"`
+	Windows        []tmuxpWindow     `yaml:"windows"`
+}
+
+type tmuxpWindow struct {
+	WindowName     string      `yaml:"window_name"`
+	Layout         string      `yaml:"layout,omitempty"`
+	StartDirectory string      `yaml:"start_directory,omitempty"`
+	Panes          []tmuxpPane `yaml:"panes"`
+}
+
+type tmuxpPane struct {
+	StartDirectory string   `yaml:"start_directory,omitempty"`
+	ShellCommand   []string `yaml:"shell_command"`
+}
+
+func tmuxp(s *session.Session, cfg *config.Config) tmuxpProject {
+	project := tmuxpProject{
+		SessionName:    s.Name,
+		StartDirectory: s.CurrentPath,
+		Environment:    s.Env,
+	}
+	for i, w := range s.Windows {
+		root := windowRoot(s, w)
+		window := tmuxpWindow{WindowName: windowName(w, i), Layout: w.Layout}
+		if root != s.CurrentPath {
+			window.StartDirectory = root
+		}
+		for _, p := range w.Panes {
+			pane := tmuxpPane{ShellCommand: tmux.PaneCommands(p, cfg)}
+			if p.CurrentPath != root {
+				pane.StartDirectory = p.CurrentPath
+			}
+			window.Panes = append(window.Panes, pane)
+		}
+		project.Windows = append(project.Windows, window)
+	}
+	return project
+}
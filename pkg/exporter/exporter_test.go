@@ -0,0 +1,103 @@
+package exporter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/importer"
+	"github.com/swit33/go-tms/pkg/session"
+)
+
+var exportSession = session.Session{
+	Name:        "shop",
+	CurrentPath: "/srv/shop",
+	Windows: []session.Window{
+		{
+			Index: "1", Name: "code", CurrentPath: "/srv/shop",
+			Panes: []session.Pane{
+				{Index: "1", CurrentPath: "/srv/shop", Command: "nvim"},
+				{Index: "2", CurrentPath: "/srv/shop", Command: "zsh", Commands: []string{"git status"}},
+			},
+		},
+		{
+			Index: "2", Name: "logs", Layout: "even-vertical", CurrentPath: "/var/log",
+			Panes: []session.Pane{
+				{Index: "1", CurrentPath: "/var/log", Commands: []string{"tail -f syslog"}},
+			},
+		},
+	},
+}
+
+func TestQuote(t *testing.T) {
+	cases := map[string]string{
+		"blog":           "blog",
+		"/srv/shop":      "/srv/shop",
+		"cd /srv/shop":   "'cd /srv/shop'",
+		"echo 'hi'":      `'echo '\''hi'\'''`,
+		"":               "''",
+		"tail -f $LOG":   "'tail -f $LOG'",
+		"go-tms/feature": "go-tms/feature",
+	}
+	for input, expected := range cases {
+		if got := Quote(input); got != expected {
+			t.Errorf("Quote(%q) = %s, expected %s", input, got, expected)
+		}
+	}
+}
+
+func TestScript(t *testing.T) {
+	cfg := &config.Config{ProgramWhitelist: "nvim"}
+	script := string(Script(&exportSession, cfg))
+	for _, line := range []string{
+		"  tmux new-session -d -s shop -c /srv/shop -n code\n",
+		"  tmux send-keys -t shop:1.1 nvim C-m\n",
+		"  tmux send-keys -t shop:1.2 'git status' C-m\n",
+		"  tmux new-window -t shop:2 -c /var/log -n logs\n",
+		"  tmux select-layout -t shop:2 even-vertical\n",
+	} {
+		if !strings.Contains(script, line) {
+			t.Errorf("expected script to contain %q, got:\n%s", line, script)
+		}
+	}
+}
+
+// Exported project files must import back into the same windows and
+// commands.
+func TestRoundTrip(t *testing.T) {
+	cfg := &config.Config{ProgramWhitelist: "nvim"}
+	expected := session.Session{
+		Name:        "shop",
+		CurrentPath: "/srv/shop",
+		Windows: []session.Window{
+			{
+				Index: "1", Name: "code", CurrentPath: "/srv/shop",
+				Panes: []session.Pane{
+					{Index: "1", CurrentPath: "/srv/shop", Commands: []string{"nvim"}},
+					{Index: "2", CurrentPath: "/srv/shop", Commands: []string{"git status"}},
+				},
+			},
+			{
+				Index: "2", Name: "logs", Layout: "even-vertical", CurrentPath: "/var/log",
+				Panes: []session.Pane{
+					{Index: "1", CurrentPath: "/var/log", Commands: []string{"tail -f syslog"}},
+				},
+			},
+		},
+	}
+
+	for _, format := range []string{FormatTmuxinator, FormatTmuxp} {
+		data, err := Export(&exportSession, format, cfg)
+		if err != nil {
+			t.Fatalf("Export(%s) failed: %v", format, err)
+		}
+		imported, err := importer.Parse(data)
+		if err != nil {
+			t.Fatalf("Parse of exported %s failed: %v\n%s", format, err, data)
+		}
+		if !reflect.DeepEqual(imported, expected) {
+			t.Errorf("%s round trip mismatch.\nExpected: %+v\nGot:      %+v\n%s", format, expected, imported, data)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package fzf
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/picker"
+	"github.com/swit33/go-tms/pkg/session"
+)
+
+const (
+	PickerAuto    = "auto"
+	PickerFZF     = "fzf"
+	PickerBuiltin = "builtin"
+)
+
+const (
+	groupActive = "Active"
+	groupSaved  = "Saved"
+	groupStale  = "Stale"
+)
+
+// sessionGroup returns the picker section of a session. Entries of other
+// sources are grouped by the source's label.
+func sessionGroup(s session.Session) string {
+	switch {
+	case s.TmuxActive:
+		return groupActive
+	case s.Source != "":
+		return s.Source
+	case s.IsStale():
+		return groupStale
+	}
+	return groupSaved
+}
+
+// sessionGroups returns the sections of the picker in order: sessions
+// first, then the other sources as they appear, then stale sessions.
+func sessionGroups(s []session.Session) []string {
+	groups := []string{groupActive, groupSaved}
+	for _, sess := range s {
+		if sess.Source != "" && !slices.Contains(groups, sess.Source) {
+			groups = append(groups, sess.Source)
+		}
+	}
+	return append(groups, groupStale)
+}
+
+// useBuiltin reports whether the native picker should be used instead of
+// fzf. In auto mode the builtin picker is only used when fzf is missing.
+func useBuiltin(cfg *config.Config) bool {
+	switch cfg.Picker {
+	case PickerBuiltin:
+		return true
+	case PickerFZF:
+		return false
+	}
+	_, err := exec.LookPath("fzf")
+	return err != nil
+}
+
+// runBuiltin runs the native picker and formats its answer the way the fzf
+// binds do, so both pickers share the same result parsing.
+func runBuiltin(opts picker.Options) (string, error) {
+	sel, err := picker.Run(opts)
+	if err != nil {
+		if errors.Is(err, picker.ErrAborted) {
+			return "", nil
+		}
+		return "", err
+	}
+	if sel.Action != "" {
+		return sel.Action + ":" + sel.Item, nil
+	}
+	return sel.Item, nil
+}
+
+func runBuiltinSessions(s []session.Session, cfg *config.Config) (string, error) {
+	bindings := Bindings(cfg)
+	binds := make([]picker.Bind, 0, len(bindings))
+	for _, b := range bindings {
+		binds = append(binds, picker.Bind{Key: b.Key, Action: string(b.Action)})
+	}
+
+	// Active sessions are listed first, the sections replace the prefix.
+	bySessionName := make(map[string]session.Session, len(s))
+	items := make([]picker.Item, 0, len(s))
+	for _, group := range sessionGroups(s) {
+		for _, sess := range s {
+			if sessionGroup(sess) != group {
+				continue
+			}
+			items = append(items, picker.Item{Text: sess.Name, Group: group})
+			bySessionName[sess.Name] = sess
+		}
+	}
+
+	opts := picker.Options{
+		Prompt: cfg.FZFPrompt,
+		Items:  items,
+		Binds:  binds,
+		Footer: footer(bindings),
+	}
+	if cfg.PickerPreview {
+		opts.Preview = func(item picker.Item) []string {
+			return SessionPreview(bySessionName[item.Text])
+		}
+	}
+	return runBuiltin(opts)
+}
+
+func runBuiltinDirectories(dirs []string) (string, error) {
+	items := make([]picker.Item, 0, len(dirs))
+	for _, dir := range dirs {
+		items = append(items, picker.Item{Text: dir})
+	}
+	return runBuiltin(picker.Options{Prompt: directoryPrompt, Items: items})
+}
+
+// SessionPreview describes the windows and panes of a session for the
+// preview pane.
+func SessionPreview(s session.Session) []string {
+	lines := []string{s.Name, s.CurrentPath}
+	if flags := s.Flags(); len(flags) > 0 {
+		lines = append(lines, strings.Join(flags, ", "))
+	}
+	lines = append(lines, "")
+	for _, w := range s.Windows {
+		lines = append(lines, fmt.Sprintf("window %s", w.Index))
+		for _, p := range w.Panes {
+			lines = append(lines, fmt.Sprintf("  %s %-8s %s", p.Index, p.Command, p.CurrentPath))
+		}
+	}
+	return lines
+}
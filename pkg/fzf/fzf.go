@@ -6,11 +6,14 @@ import (
 	"github.com/swit33/go-tms/pkg/session"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 )
 
 const ActionPrefix = "gotms_act_"
 
+const directoryPrompt = "Projects> "
+
 // const TmuxActivePrefix = " "
 
 type Action string
@@ -21,9 +24,25 @@ const (
 	ActionInteractive Action = ActionPrefix + "interactive"
 	ActionSave        Action = ActionPrefix + "save"
 	ActionKill        Action = ActionPrefix + "kill"
+	ActionWorktree    Action = ActionPrefix + "worktree"
+	ActionPin         Action = ActionPrefix + "pin"
+	ActionAutostart   Action = ActionPrefix + "autostart"
+	ActionFreeze      Action = ActionPrefix + "freeze"
 	ActionReturn      Action = ActionPrefix + "return"
+	// ActionCustom prefixes the index of a configured action.
+	ActionCustom Action = ActionPrefix + "custom_"
 )
 
+// Custom returns the index of the configured action a custom action runs.
+func (a Action) Custom() (int, bool) {
+	index, ok := strings.CutPrefix(string(a), string(ActionCustom))
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.Atoi(index)
+	return i, err == nil
+}
+
 type Result struct {
 	Action      Action
 	IsAction    bool
@@ -31,28 +50,62 @@ type Result struct {
 	SessionName string
 }
 
+type Binding struct {
+	Key    string
+	Action Action
+	Label  string
+}
+
+// Bindings returns the built-in and configured picker actions bound to keys,
+// in footer order.
+func Bindings(cfg *config.Config) []Binding {
+	bindings := []Binding{
+		{Key: cfg.FZFBindNew, Action: ActionNew, Label: "new session"},
+		{Key: cfg.FZFBindDelete, Action: ActionDelete, Label: "delete session"},
+		{Key: cfg.FZFBindInteractive, Action: ActionInteractive, Label: "interactive search"},
+		{Key: cfg.FZFBindSave, Action: ActionSave, Label: "save session"},
+		{Key: cfg.FZFBindKill, Action: ActionKill, Label: "kill session"},
+		{Key: cfg.FZFBindWorktree, Action: ActionWorktree, Label: "new worktree"},
+		{Key: cfg.FZFBindPin, Action: ActionPin, Label: "toggle pinned"},
+		{Key: cfg.FZFBindAutostart, Action: ActionAutostart, Label: "toggle autostart"},
+		{Key: cfg.FZFBindFreeze, Action: ActionFreeze, Label: "toggle frozen"},
+	}
+	for i, a := range cfg.Actions {
+		label := a.Label
+		if label == "" {
+			label = a.Command
+		}
+		bindings = append(bindings, Binding{Key: a.Key, Action: Action(fmt.Sprint(ActionCustom, i)), Label: label})
+	}
+	return bindings
+}
+
+func footer(bindings []Binding) string {
+	lines := make([]string, 0, len(bindings))
+	for _, b := range bindings {
+		lines = append(lines, fmt.Sprintf("<%s>: %s", b.Key, b.Label))
+	}
+	return strings.Join(lines, "\n")
+}
+
 func Run(entries []string, cfg *config.Config) (string, error) {
+	bindings := Bindings(cfg)
 	var binds []string
-	binds = append(binds,
-		fmt.Sprintf("--bind=%s:become(echo '%s:{}')", cfg.FZFBindNew, ActionNew))
-	binds = append(binds,
-		fmt.Sprintf("--bind=%s:become(echo '%s:{}')", cfg.FZFBindDelete, ActionDelete))
-	binds = append(binds,
-		fmt.Sprintf("--bind=%s:become(echo '%s:{}')", cfg.FZFBindInteractive, ActionInteractive))
-	binds = append(binds,
-		fmt.Sprintf("--bind=%s:become(echo '%s:{}')", cfg.FZFBindSave, ActionSave))
-	binds = append(binds,
-		fmt.Sprintf("--bind=%s:become(echo '%s:{}')", cfg.FZFBindKill, ActionKill))
-	var footer []string
-	footer = append(footer, "--footer")
-	footer = append(footer,
-		fmt.Sprintf("<%s>: new session\n<%s>: delete session\n<%s>: interactive search\n<%s>: save session\n<%s>: kill session",
-			cfg.FZFBindNew, cfg.FZFBindDelete, cfg.FZFBindInteractive, cfg.FZFBindSave, cfg.FZFBindKill))
+	for _, b := range bindings {
+		binds = append(binds, fmt.Sprintf("--bind=%s:become(echo '%s:{}')", b.Key, b.Action))
+	}
+	var footerArgs []string
+	footerArgs = append(footerArgs, "--footer")
+	footerArgs = append(footerArgs, footer(bindings))
 	args := []string{}
 	args = append(args, strings.Fields(cfg.FZFOpts)...)
 	args = append(args, binds...)
 	args = append(args, "--prompt", cfg.FZFPrompt)
-	args = append(args, footer...)
+	args = append(args, footerArgs...)
+	return runFZF(args, entries)
+}
+
+func runFZF(args []string, entries []string) (string, error) {
 	cmd := exec.Command("fzf", args...)
 	if len(entries) != 0 {
 		cmd.Stdin = strings.NewReader(strings.Join(entries, "\n"))
@@ -76,15 +129,25 @@ func Run(entries []string, cfg *config.Config) (string, error) {
 }
 
 func RunSessions(s []session.Session, cfg *config.Config) (Result, error) {
-	entries := make([]string, 0)
-	for _, s := range s {
-		if s.TmuxActive {
-			entries = append(entries, cfg.ActiveSessionPrefix+s.Name)
-		} else {
-			entries = append(entries, s.Name)
+	var result string
+	var err error
+	if useBuiltin(cfg) {
+		result, err = runBuiltinSessions(s, cfg)
+	} else {
+		entries := make([]string, 0)
+		for _, s := range s {
+			if s.TmuxActive {
+				entries = append(entries, cfg.ActiveSessionPrefix+s.Name)
+			} else if s.Source != "" {
+				entries = append(entries, sourceEntry(s))
+			} else if s.IsStale() {
+				entries = append(entries, cfg.StaleSessionPrefix+s.Name)
+			} else {
+				entries = append(entries, s.Name)
+			}
 		}
+		result, err = Run(entries, cfg)
 	}
-	result, err := Run(entries, cfg)
 	if err != nil {
 		return Result{}, err
 	}
@@ -94,30 +157,50 @@ func RunSessions(s []session.Session, cfg *config.Config) (Result, error) {
 	}
 
 	if strings.HasPrefix(result, ActionPrefix) {
-		parts := strings.Split(result, ":")
-		if strings.HasPrefix(parts[1], cfg.ActiveSessionPrefix) {
-			parts[1] = parts[1][len(cfg.ActiveSessionPrefix):]
-		}
-		return Result{IsAction: true, Action: Action(parts[0]), Arg: parts[1]}, nil
+		parts := strings.SplitN(result, ":", 2)
+		return Result{IsAction: true, Action: Action(parts[0]), Arg: trimEntryPrefix(parts[1], cfg)}, nil
 	}
 	sessionName := strings.TrimSpace(string(result))
-	if strings.HasPrefix(sessionName, cfg.ActiveSessionPrefix) {
-		sessionName = sessionName[len(cfg.ActiveSessionPrefix):]
+	return Result{IsAction: false, SessionName: trimEntryPrefix(sessionName, cfg)}, nil
+}
+
+// sourceEntry labels an entry offered by a picker source, such as
+// "[Projects]\tapi", the same way the builtin picker groups them.
+func sourceEntry(s session.Session) string {
+	return "[" + s.Source + "]\t" + s.Name
+}
+
+// trimEntryPrefix strips the marker an fzf entry was given for an active or
+// stale session, or the label of a picker source.
+func trimEntryPrefix(entry string, cfg *config.Config) string {
+	if _, name, ok := strings.Cut(entry, "\t"); ok {
+		return name
 	}
-	return Result{IsAction: false, SessionName: sessionName}, nil
+	for _, prefix := range []string{cfg.ActiveSessionPrefix, cfg.StaleSessionPrefix} {
+		if prefix != "" && strings.HasPrefix(entry, prefix) {
+			return entry[len(prefix):]
+		}
+	}
+	return entry
 }
 
-func RunZoxide(cfg *config.Config) (Result, error) {
-	cmd := exec.Command("zoxide", "query", "-i")
-	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, "_ZO_FZF_OPTS="+cfg.ZoxideOpts)
-	output, err := cmd.Output()
+// RunDirectories lets the user pick a directory to open, from zoxide and
+// project discovery results.
+func RunDirectories(dirs []string, cfg *config.Config) (Result, error) {
+	var path string
+	var err error
+	if useBuiltin(cfg) {
+		path, err = runBuiltinDirectories(dirs)
+	} else {
+		args := strings.Fields(cfg.ZoxideOpts)
+		args = append(args, "--prompt", directoryPrompt)
+		path, err = runFZF(args, dirs)
+	}
 	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 130 {
-			return Result{IsAction: true, Action: ActionReturn}, nil
-		}
-		return Result{}, fmt.Errorf("zoxide command failed: %v", err)
+		return Result{}, err
+	}
+	if path == "" {
+		return Result{IsAction: true, Action: ActionReturn}, nil
 	}
-	path := strings.TrimSpace(string(output))
 	return Result{IsAction: true, Action: ActionInteractive, Arg: path}, nil
 }
@@ -0,0 +1,26 @@
+package fzf
+
+import (
+	"testing"
+
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/session"
+)
+
+func TestTrimEntryPrefix(t *testing.T) {
+	cfg := config.Default()
+	tests := []struct {
+		entry    string
+		expected string
+	}{
+		{"work", "work"},
+		{cfg.ActiveSessionPrefix + "work", "work"},
+		{cfg.StaleSessionPrefix + "work", "work"},
+		{sourceEntry(session.Session{Name: "api", Source: "Projects"}), "api"},
+	}
+	for _, tt := range tests {
+		if name := trimEntryPrefix(tt.entry, &cfg); name != tt.expected {
+			t.Errorf("trimEntryPrefix(%q) = %q, expected %q", tt.entry, name, tt.expected)
+		}
+	}
+}
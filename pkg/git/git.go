@@ -0,0 +1,203 @@
+// Package git detects repositories and worktrees so sessions can be named
+// after the repository they belong to.
+package git
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/swit33/go-tms/pkg/session"
+)
+
+type Repo struct {
+	// Name is the repository name, taken from the main worktree directory.
+	Name string
+	// Root is the path of the main worktree (or the bare repository).
+	Root string
+	// Worktree is the top level of the worktree containing the path.
+	Worktree string
+	Branch   string
+	Linked   bool
+}
+
+type Worktree struct {
+	Path   string
+	Branch string
+}
+
+// Detect returns the repository containing path. It reports false when path
+// is not inside a git repository or git is not installed.
+func Detect(path string) (Repo, bool, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return Repo{}, false, nil
+	}
+	cmd := exec.Command("git", "-C", path, "rev-parse", "--show-toplevel", "--git-common-dir", "--abbrev-ref", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return Repo{}, false, nil
+		}
+		return Repo{}, false, fmt.Errorf("failed to detect git repository: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 3 {
+		return Repo{}, false, nil
+	}
+
+	toplevel := filepath.Clean(lines[0])
+	commonDir := lines[1]
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(path, commonDir)
+	}
+	commonDir = filepath.Clean(commonDir)
+
+	root := commonDir
+	if filepath.Base(commonDir) == ".git" {
+		root = filepath.Dir(commonDir)
+	}
+
+	return Repo{
+		Name:     strings.TrimSuffix(filepath.Base(root), ".git"),
+		Root:     root,
+		Worktree: toplevel,
+		Branch:   strings.TrimPrefix(lines[2], "heads/"),
+		Linked:   toplevel != root,
+	}, true, nil
+}
+
+// WorktreeName is the worktree directory for linked worktrees and the
+// checked out branch for the main one.
+func (r Repo) WorktreeName() string {
+	if r.Linked || r.Branch == "HEAD" || r.Branch == "" {
+		return filepath.Base(r.Worktree)
+	}
+	return r.Branch
+}
+
+// SessionName expands a template such as "{repo}/{worktree}".
+func SessionName(template string, r Repo) string {
+	return strings.NewReplacer(
+		"{repo}", r.Name,
+		"{worktree}", r.WorktreeName(),
+		"{branch}", r.Branch,
+	).Replace(template)
+}
+
+// Worktrees lists the worktrees of the repository rooted at root.
+func Worktrees(root string) ([]Worktree, error) {
+	cmd := exec.Command("git", "-C", root, "worktree", "list", "--porcelain")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worktrees of %s: %v", root, err)
+	}
+
+	var worktrees []Worktree
+	var current *Worktree
+	for line := range strings.SplitSeq(string(output), "\n") {
+		switch {
+		case strings.HasPrefix(line, "worktree "):
+			worktrees = append(worktrees, Worktree{Path: strings.TrimPrefix(line, "worktree ")})
+			current = &worktrees[len(worktrees)-1]
+		case strings.HasPrefix(line, "branch ") && current != nil:
+			current.Branch = strings.TrimPrefix(line, "branch refs/heads/")
+		case line == "bare" && current != nil:
+			// A bare repository has no working tree to open.
+			worktrees = worktrees[:len(worktrees)-1]
+			current = nil
+		}
+	}
+	return worktrees, nil
+}
+
+// WorktreePath expands a template such as "{root}/../{repo}-{branch}" into
+// the directory for a new worktree of the repository.
+func WorktreePath(template string, r Repo, branch string) string {
+	path := strings.NewReplacer(
+		"{root}", r.Root,
+		"{repo}", r.Name,
+		"{branch}", strings.ReplaceAll(branch, "/", "-"),
+	).Replace(template)
+	return filepath.Clean(path)
+}
+
+// AddWorktree checks out branch into a new worktree at path, creating the
+// branch from HEAD if it does not exist yet.
+func AddWorktree(r Repo, path string, branch string) error {
+	args := []string{"-C", r.Root, "worktree", "add", path, branch}
+	verify := exec.Command("git", "-C", r.Root, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	if err := verify.Run(); err != nil {
+		args = []string{"-C", r.Root, "worktree", "add", "-b", branch, path}
+	}
+	cmd := exec.Command("git", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to add worktree: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// WorktreeSessions returns picker entries for worktrees of the repositories
+// the given sessions live in that do not have a session yet.
+func WorktreeSessions(sessions []session.Session, template string) ([]session.Session, error) {
+	covered := make(map[string]bool)
+	names := make(map[string]bool)
+	var repos []Repo
+	for _, s := range sessions {
+		names[s.Name] = true
+		if s.CurrentPath == "" {
+			continue
+		}
+		covered[filepath.Clean(s.CurrentPath)] = true
+		repo, ok, err := Detect(s.CurrentPath)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			covered[repo.Worktree] = true
+			repos = append(repos, repo)
+		}
+	}
+
+	seenRoots := make(map[string]bool)
+	var candidates []session.Session
+	for _, repo := range repos {
+		if seenRoots[repo.Root] {
+			continue
+		}
+		seenRoots[repo.Root] = true
+
+		worktrees, err := Worktrees(repo.Root)
+		if err != nil {
+			continue
+		}
+		for _, wt := range worktrees {
+			wtRepo := repo
+			wtRepo.Worktree = filepath.Clean(wt.Path)
+			wtRepo.Branch = wt.Branch
+			wtRepo.Linked = wtRepo.Worktree != repo.Root
+			if covered[wtRepo.Worktree] {
+				continue
+			}
+			name := session.SanitizeName(SessionName(template, wtRepo))
+			if names[name] {
+				continue
+			}
+			names[name] = true
+			candidates = append(candidates, session.Session{
+				Name:        name,
+				CurrentPath: wtRepo.Worktree,
+				Source:      session.SourceWorktree,
+			})
+		}
+	}
+	return candidates, nil
+}
+
+// WorktreeSource offers the worktrees of the repositories the sessions listed
+// before it live in.
+func WorktreeSource(template string) session.Source {
+	return session.NewSource(session.SourceWorktree, func(listed []session.Session) ([]session.Session, error) {
+		return WorktreeSessions(listed, template)
+	})
+}
@@ -0,0 +1,86 @@
+package git
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/swit33/go-tms/pkg/session"
+)
+
+func gitCmd(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, output)
+	}
+}
+
+func TestWorktrees(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	tmp, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(tmp, "go-tms")
+	gitCmd(t, tmp, "init", "-q", "-b", "main", root)
+	gitCmd(t, root, "commit", "-q", "--allow-empty", "-m", "init")
+
+	t.Run("DetectMain", func(t *testing.T) {
+		repo, ok, err := Detect(root)
+		if err != nil || !ok {
+			t.Fatalf("expected repository to be detected, got ok=%v err=%v", ok, err)
+		}
+		if repo.Name != "go-tms" || repo.Root != root || repo.Linked {
+			t.Errorf("unexpected repository %+v", repo)
+		}
+		if name := SessionName("{repo}/{worktree}", repo); name != "go-tms/main" {
+			t.Errorf("expected session name 'go-tms/main', got '%s'", name)
+		}
+	})
+
+	t.Run("AddAndDetectLinked", func(t *testing.T) {
+		repo, _, _ := Detect(root)
+		path := WorktreePath("{root}/../{repo}-{branch}", repo, "feature/x")
+		if path != filepath.Join(tmp, "go-tms-feature-x") {
+			t.Fatalf("unexpected worktree path '%s'", path)
+		}
+		if err := AddWorktree(repo, path, "feature/x"); err != nil {
+			t.Fatalf("AddWorktree failed: %v", err)
+		}
+
+		linked, ok, err := Detect(path)
+		if err != nil || !ok {
+			t.Fatalf("expected worktree to be detected, got ok=%v err=%v", ok, err)
+		}
+		if !linked.Linked || linked.Root != root || linked.Branch != "feature/x" {
+			t.Errorf("unexpected worktree %+v", linked)
+		}
+		if name := SessionName("{repo}/{worktree}", linked); name != "go-tms/go-tms-feature-x" {
+			t.Errorf("expected session name 'go-tms/go-tms-feature-x', got '%s'", name)
+		}
+	})
+
+	t.Run("WorktreeSessions", func(t *testing.T) {
+		sessions := []session.Session{{Name: "go-tms/main", CurrentPath: root}}
+		candidates, err := WorktreeSessions(sessions, "{repo}/{branch}")
+		if err != nil {
+			t.Fatalf("WorktreeSessions failed: %v", err)
+		}
+		if len(candidates) != 1 {
+			t.Fatalf("expected one worktree candidate, got %+v", candidates)
+		}
+		c := candidates[0]
+		if c.Name != "go-tms/feature/x" || c.Source != session.SourceWorktree {
+			t.Errorf("unexpected candidate %+v", c)
+		}
+	})
+
+	t.Run("NotARepository", func(t *testing.T) {
+		if _, ok, err := Detect(tmp); ok || err != nil {
+			t.Errorf("expected plain directory not to be a repository, got ok=%v err=%v", ok, err)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+// Package hooks runs the user commands configured around session lifecycle
+// events.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/swit33/go-tms/pkg/config"
+)
+
+// Event is a session lifecycle event, one of config.HookEvents.
+type Event string
+
+const (
+	Create  Event = "create"
+	Restore Event = "restore"
+	Switch  Event = "switch"
+	Save    Event = "save"
+	Kill    Event = "kill"
+)
+
+// Context describes the session an event concerns. Save events concern all
+// sessions and leave it empty.
+type Context struct {
+	Name string
+	Path string
+}
+
+// Pre runs the pre- hook of the event. A failing hook vetoes the event, so
+// the caller must not carry it out when an error is returned.
+func Pre(cfg *config.Config, event Event, ctx Context) error {
+	if err := run(cfg, "pre-"+string(event), ctx); err != nil {
+		return fmt.Errorf("%s cancelled by pre-%s hook: %v", event, event, err)
+	}
+	return nil
+}
+
+// Post runs the post- hook of the event after it was carried out.
+func Post(cfg *config.Config, event Event, ctx Context) error {
+	if err := run(cfg, "post-"+string(event), ctx); err != nil {
+		return fmt.Errorf("post-%s hook failed: %v", event, err)
+	}
+	return nil
+}
+
+// Env returns the variables describing the hook and its session.
+func Env(hook string, ctx Context) []string {
+	return []string{
+		"TMS_HOOK=" + hook,
+		"TMS_SESSION_NAME=" + ctx.Name,
+		"TMS_SESSION_PATH=" + ctx.Path,
+	}
+}
+
+func run(cfg *config.Config, hook string, ctx Context) error {
+	command := cfg.Hooks[hook]
+	if command == "" {
+		return nil
+	}
+	timeout := time.Duration(cfg.HookTimeoutSeconds) * time.Second
+	runCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(runCtx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), Env(hook, ctx)...)
+	if info, err := os.Stat(ctx.Path); err == nil && info.IsDir() {
+		cmd.Dir = ctx.Path
+	}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Background processes started by the hook may keep the output open.
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		if lines := strings.Split(strings.TrimSpace(output.String()), "\n"); lines[len(lines)-1] != "" {
+			return fmt.Errorf("%v: %s", err, lines[len(lines)-1])
+		}
+		return err
+	}
+	return nil
+}
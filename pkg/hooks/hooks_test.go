@@ -0,0 +1,55 @@
+package hooks
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/swit33/go-tms/pkg/config"
+)
+
+func TestEventsAreConfigurable(t *testing.T) {
+	for _, event := range []Event{Create, Restore, Switch, Save, Kill} {
+		if !slices.Contains(config.HookEvents, string(event)) {
+			t.Errorf("event %s is missing from config.HookEvents", event)
+		}
+	}
+}
+
+func TestHooks(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	cfg := config.Default()
+	cfg.HookTimeoutSeconds = 1
+	cfg.Hooks = map[string]string{
+		"post-restore": `echo "$TMS_HOOK $TMS_SESSION_NAME $PWD" > ` + out,
+		"pre-kill":     "echo compose is busy >&2; exit 1",
+		"pre-switch":   "sleep 5",
+	}
+	ctx := Context{Name: "api", Path: dir}
+
+	if err := Pre(&cfg, Restore, ctx); err != nil {
+		t.Errorf("expected an unset hook to pass, got %v", err)
+	}
+	if err := Post(&cfg, Restore, ctx); err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := strings.TrimSpace(string(data)), "post-restore api "+dir; got != expected {
+		t.Errorf("hook saw %q, expected %q", got, expected)
+	}
+
+	err = Pre(&cfg, Kill, ctx)
+	if err == nil || !strings.Contains(err.Error(), "cancelled by pre-kill hook") || !strings.Contains(err.Error(), "compose is busy") {
+		t.Errorf("expected the pre-kill hook to veto with its output, got %v", err)
+	}
+	err = Pre(&cfg, Switch, ctx)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected the pre-switch hook to time out, got %v", err)
+	}
+}
@@ -0,0 +1,407 @@
+// Package importer reads tmuxinator, tmuxp and smug project files into
+// sessions.
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/swit33/go-tms/pkg/session"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FormatTmuxinator = "tmuxinator"
+	FormatTmuxp      = "tmuxp"
+	FormatSmug       = "smug"
+)
+
+// DefaultDirs lists where the supported tools keep their project files.
+var DefaultDirs = []string{
+	"~/.tmuxinator",
+	"~/.config/tmuxinator",
+	"~/.tmuxp",
+	"~/.config/tmuxp",
+	"~/.config/smug",
+}
+
+// LoadFile parses a project file of any supported format into a session
+// with absolute paths.
+func LoadFile(path string) (session.Session, error) {
+	s, err := readFile(path)
+	if err != nil {
+		return session.Session{}, err
+	}
+	return finalize(s)
+}
+
+// readFile parses a project file, leaving paths relative to their parent.
+func readFile(path string) (session.Session, error) {
+	path, err := session.ExpandHome(path)
+	if err != nil {
+		return session.Session{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return session.Session{}, err
+	}
+	s, err := parse(data)
+	if err != nil {
+		return session.Session{}, fmt.Errorf("failed to import %s: %v", path, err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	s.Name = session.SanitizeName(s.Name)
+	return s, nil
+}
+
+// FindFiles returns the project files in the default directories.
+func FindFiles() ([]string, error) {
+	var files []string
+	for _, dir := range DefaultDirs {
+		dir, err := session.ExpandHome(dir)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			switch filepath.Ext(e.Name()) {
+			case ".yml", ".yaml", ".json":
+				if !e.IsDir() {
+					files = append(files, filepath.Join(dir, e.Name()))
+				}
+			}
+		}
+	}
+	return files, nil
+}
+
+// DetectFormat guesses the tool a project file was written for from its
+// top level keys.
+func DetectFormat(doc map[string]any) (string, error) {
+	switch {
+	case doc["session_name"] != nil:
+		return FormatTmuxp, nil
+	case doc["session"] != nil:
+		return FormatSmug, nil
+	case doc["windows"] != nil || doc["tabs"] != nil:
+		return FormatTmuxinator, nil
+	}
+	return "", fmt.Errorf("unrecognized project file format")
+}
+
+// Parse converts a project file into a session with absolute paths. A
+// session without a root starts in the home directory.
+func Parse(data []byte) (session.Session, error) {
+	s, err := parse(data)
+	if err != nil {
+		return session.Session{}, err
+	}
+	return finalize(s)
+}
+
+// parse converts a project file into a session with its paths as written,
+// environment variables expanded.
+func parse(data []byte) (session.Session, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return session.Session{}, err
+	}
+	format, err := DetectFormat(doc)
+	if err != nil {
+		return session.Session{}, err
+	}
+
+	var s session.Session
+	switch format {
+	case FormatTmuxp:
+		s, err = parseTmuxp(doc)
+	case FormatSmug:
+		s, err = parseSmug(doc)
+	default:
+		s, err = parseTmuxinator(doc)
+	}
+	if err != nil {
+		return session.Session{}, err
+	}
+	return s.MapPaths(os.ExpandEnv), nil
+}
+
+// ResolveTemplate fills a template that references a project file with the
+// windows of that file. Settings in the template itself take precedence.
+// Paths stay relative and the file's root is ignored so they resolve against
+// the directory the template is instantiated for.
+func ResolveTemplate(t session.Template) (session.Template, error) {
+	if t.File == "" {
+		return t, nil
+	}
+	s, err := readFile(t.File)
+	if err != nil {
+		return t, err
+	}
+	if t.Name == "" {
+		t.Name = s.Name
+	}
+	if len(t.Windows) == 0 {
+		t.Windows = s.Windows
+	}
+	if len(t.Env) == 0 {
+		t.Env = s.Env
+	}
+	return t, nil
+}
+
+// finalize makes every path absolute and numbers windows and panes.
+func finalize(s session.Session) (session.Session, error) {
+	root, err := resolve("", s.CurrentPath)
+	if err != nil {
+		return s, err
+	}
+	s.CurrentPath = root
+	for i := range s.Windows {
+		w := &s.Windows[i]
+		w.Index = fmt.Sprint(i + 1)
+		if w.CurrentPath, err = resolve(root, w.CurrentPath); err != nil {
+			return s, err
+		}
+		if len(w.Panes) == 0 {
+			w.Panes = []session.Pane{{}}
+		}
+		for j := range w.Panes {
+			p := &w.Panes[j]
+			p.Index = fmt.Sprint(j + 1)
+			if p.CurrentPath, err = resolve(w.CurrentPath, p.CurrentPath); err != nil {
+				return s, err
+			}
+		}
+	}
+	return s, nil
+}
+
+func resolve(base string, path string) (string, error) {
+	if path == "" {
+		if base == "" {
+			return os.UserHomeDir()
+		}
+		return base, nil
+	}
+	path, err := session.ExpandHome(path)
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(path) || base == "" {
+		return filepath.Abs(path)
+	}
+	return filepath.Join(base, path), nil
+}
+
+func str(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// commands accepts a single command or a list of commands.
+func commands(v any) []string {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		var cmds []string
+		for _, c := range v {
+			cmds = append(cmds, commands(c)...)
+		}
+		return cmds
+	case map[string]any:
+		// tmuxp allows {cmd: ...} entries in shell_command lists.
+		return commands(v["cmd"])
+	default:
+		if s := str(v); s != "" {
+			return []string{s}
+		}
+		return nil
+	}
+}
+
+func env(v any) map[string]string {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(m))
+	for k, val := range m {
+		result[k] = str(val)
+	}
+	return result
+}
+
+// prepend adds commands before every pane's own commands.
+func prepend(panes []session.Pane, before []string) {
+	if len(before) == 0 {
+		return
+	}
+	for i := range panes {
+		panes[i].Commands = append(append([]string{}, before...), panes[i].Commands...)
+	}
+}
+
+// runOnce adds one-off session commands to the first pane of the session.
+func runOnce(s *session.Session, cmds []string) {
+	if len(cmds) == 0 || len(s.Windows) == 0 {
+		return
+	}
+	w := &s.Windows[0]
+	if len(w.Panes) == 0 {
+		w.Panes = []session.Pane{{}}
+	}
+	w.Panes[0].Commands = append(append([]string{}, cmds...), w.Panes[0].Commands...)
+}
+
+func parseTmuxinator(doc map[string]any) (session.Session, error) {
+	s := session.Session{
+		Name:        str(doc["name"]),
+		CurrentPath: str(doc["root"]),
+	}
+	if s.Name == "" {
+		s.Name = str(doc["project_name"])
+	}
+	if s.CurrentPath == "" {
+		s.CurrentPath = str(doc["project_root"])
+	}
+
+	windows, ok := doc["windows"].([]any)
+	if !ok {
+		windows, _ = doc["tabs"].([]any)
+	}
+	for _, entry := range windows {
+		m, ok := entry.(map[string]any)
+		if !ok || len(m) != 1 {
+			return s, fmt.Errorf("invalid tmuxinator window: %v", entry)
+		}
+		for name, value := range m {
+			w := session.Window{Name: name}
+			switch value := value.(type) {
+			case map[string]any:
+				w.Layout = str(value["layout"])
+				w.CurrentPath = str(value["root"])
+				panes, _ := value["panes"].([]any)
+				for _, p := range panes {
+					pane := session.Pane{}
+					if named, ok := p.(map[string]any); ok {
+						for _, cmds := range named {
+							pane.Commands = append(pane.Commands, commands(cmds)...)
+						}
+					} else {
+						pane.Commands = commands(p)
+					}
+					w.Panes = append(w.Panes, pane)
+				}
+				if len(w.Panes) == 0 {
+					w.Panes = []session.Pane{{}}
+				}
+				prepend(w.Panes, commands(value["pre"]))
+			default:
+				w.Panes = []session.Pane{{Commands: commands(value)}}
+			}
+			prepend(w.Panes, commands(doc["pre_window"]))
+			s.Windows = append(s.Windows, w)
+		}
+	}
+	runOnce(&s, append(commands(doc["on_project_start"]), commands(doc["pre"])...))
+	return s, nil
+}
+
+func parseTmuxp(doc map[string]any) (session.Session, error) {
+	s := session.Session{
+		Name:        str(doc["session_name"]),
+		CurrentPath: str(doc["start_directory"]),
+		Env:         env(doc["environment"]),
+	}
+	sessionBefore := commands(doc["shell_command_before"])
+
+	windows, _ := doc["windows"].([]any)
+	for _, entry := range windows {
+		m, ok := entry.(map[string]any)
+		if !ok {
+			return s, fmt.Errorf("invalid tmuxp window: %v", entry)
+		}
+		w := session.Window{
+			Name:        str(m["window_name"]),
+			Layout:      str(m["layout"]),
+			CurrentPath: str(m["start_directory"]),
+		}
+		panes, _ := m["panes"].([]any)
+		for _, p := range panes {
+			pane := session.Pane{}
+			if pm, ok := p.(map[string]any); ok {
+				pane.Commands = commands(pm["shell_command"])
+				pane.CurrentPath = str(pm["start_directory"])
+			} else {
+				pane.Commands = commands(p)
+			}
+			w.Panes = append(w.Panes, pane)
+		}
+		if len(w.Panes) == 0 {
+			w.Panes = []session.Pane{{}}
+		}
+		prepend(w.Panes, commands(m["shell_command_before"]))
+		prepend(w.Panes, sessionBefore)
+		s.Windows = append(s.Windows, w)
+	}
+	runOnce(&s, commands(doc["before_script"]))
+	return s, nil
+}
+
+func parseSmug(doc map[string]any) (session.Session, error) {
+	s := session.Session{
+		Name:        str(doc["session"]),
+		CurrentPath: str(doc["root"]),
+		Env:         env(doc["env"]),
+	}
+
+	windows, _ := doc["windows"].([]any)
+	for _, entry := range windows {
+		m, ok := entry.(map[string]any)
+		if !ok {
+			return s, fmt.Errorf("invalid smug window: %v", entry)
+		}
+		w := session.Window{
+			Name:        str(m["name"]),
+			Layout:      str(m["layout"]),
+			CurrentPath: str(m["root"]),
+			Panes:       []session.Pane{{Commands: commands(m["commands"])}},
+		}
+		panes, _ := m["panes"].([]any)
+		for _, p := range panes {
+			pm, ok := p.(map[string]any)
+			if !ok {
+				return s, fmt.Errorf("invalid smug pane: %v", p)
+			}
+			pane := session.Pane{
+				Commands:    commands(pm["commands"]),
+				CurrentPath: str(pm["root"]),
+			}
+			switch str(pm["type"]) {
+			case "horizontal":
+				pane.Split = "h"
+			case "vertical":
+				pane.Split = "v"
+			}
+			w.Panes = append(w.Panes, pane)
+		}
+		s.Windows = append(s.Windows, w)
+	}
+	runOnce(&s, commands(doc["before_start"]))
+	return s, nil
+}
@@ -0,0 +1,182 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/swit33/go-tms/pkg/session"
+)
+
+type ImportTestCase struct {
+	Name     string
+	Input    string
+	Expected session.Session
+}
+
+var importTestCases = []ImportTestCase{
+	{
+		Name: "tmuxinator",
+		Input: `
+name: blog
+root: /srv/blog
+pre_window: nvm use
+windows:
+  - editor:
+      layout: main-vertical
+      panes:
+        - vim
+        - guard
+  - server: bundle exec rails s
+  - logs:
+      root: log
+      panes:
+        - tail:
+            - tail -f development.log
+`,
+		Expected: session.Session{
+			Name:        "blog",
+			CurrentPath: "/srv/blog",
+			Windows: []session.Window{
+				{
+					Index: "1", Name: "editor", Layout: "main-vertical", CurrentPath: "/srv/blog",
+					Panes: []session.Pane{
+						{Index: "1", CurrentPath: "/srv/blog", Commands: []string{"nvm use", "vim"}},
+						{Index: "2", CurrentPath: "/srv/blog", Commands: []string{"nvm use", "guard"}},
+					},
+				},
+				{
+					Index: "2", Name: "server", CurrentPath: "/srv/blog",
+					Panes: []session.Pane{
+						{Index: "1", CurrentPath: "/srv/blog", Commands: []string{"nvm use", "bundle exec rails s"}},
+					},
+				},
+				{
+					Index: "3", Name: "logs", CurrentPath: "/srv/blog/log",
+					Panes: []session.Pane{
+						{Index: "1", CurrentPath: "/srv/blog/log", Commands: []string{"nvm use", "tail -f development.log"}},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "tmuxp",
+		Input: `
+session_name: api
+start_directory: /srv/api
+environment:
+  APP_ENV: dev
+shell_command_before:
+  - source .env
+windows:
+  - window_name: dev
+    layout: tiled
+    panes:
+      - shell_command:
+          - make run
+      - echo hello
+      - start_directory: /var/log
+        shell_command: tail -f syslog
+`,
+		Expected: session.Session{
+			Name:        "api",
+			CurrentPath: "/srv/api",
+			Env:         map[string]string{"APP_ENV": "dev"},
+			Windows: []session.Window{
+				{
+					Index: "1", Name: "dev", Layout: "tiled", CurrentPath: "/srv/api",
+					Panes: []session.Pane{
+						{Index: "1", CurrentPath: "/srv/api", Commands: []string{"source .env", "make run"}},
+						{Index: "2", CurrentPath: "/srv/api", Commands: []string{"source .env", "echo hello"}},
+						{Index: "3", CurrentPath: "/var/log", Commands: []string{"source .env", "tail -f syslog"}},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "smug",
+		Input: `
+session: shop
+root: /srv/shop
+before_start:
+  - docker compose up -d
+windows:
+  - name: code
+    root: web
+    commands:
+      - nvim
+    panes:
+      - type: horizontal
+        root: .
+        commands:
+          - npm start
+`,
+		Expected: session.Session{
+			Name:        "shop",
+			CurrentPath: "/srv/shop",
+			Windows: []session.Window{
+				{
+					Index: "1", Name: "code", CurrentPath: "/srv/shop/web",
+					Panes: []session.Pane{
+						{Index: "1", CurrentPath: "/srv/shop/web", Commands: []string{"docker compose up -d", "nvim"}},
+						{Index: "2", CurrentPath: "/srv/shop/web", Split: "h", Commands: []string{"npm start"}},
+					},
+				},
+			},
+		},
+	},
+}
+
+func TestParse(t *testing.T) {
+	for _, testCase := range importTestCases {
+		got, err := Parse([]byte(testCase.Input))
+		if err != nil {
+			t.Errorf("Parse() in case %s error = %v", testCase.Name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, testCase.Expected) {
+			t.Errorf("Parse() in case %s did not produce the expected session.\nExpected: %+v\nGot:      %+v",
+				testCase.Name, testCase.Expected, got)
+		}
+	}
+
+	if _, err := Parse([]byte("foo: bar")); err == nil {
+		t.Errorf("expected an error for an unrecognized project file")
+	}
+}
+
+func TestResolveTemplate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "api.yml")
+	data := `
+name: api
+root: /srv/api
+windows:
+  - editor: nvim
+  - server:
+      root: backend
+      panes:
+        - make run
+`
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := ResolveTemplate(session.Template{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dir, "checkout")
+	s, err := tmpl.Instantiate("api", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.CurrentPath != target {
+		t.Errorf("expected the session to start in %s, got %s", target, s.CurrentPath)
+	}
+	if got := s.Windows[1].Panes[0].CurrentPath; got != filepath.Join(target, "backend") {
+		t.Errorf("expected the server pane below the target directory, got %s", got)
+	}
+}
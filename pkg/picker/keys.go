@@ -0,0 +1,156 @@
+package picker
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// key is a single decoded keypress. Named keys use fzf's key names
+// (ctrl-n, alt-x, enter, btab, ...) so binds from the config can be shared
+// between fzf and the builtin picker; printable input is carried in char.
+type key struct {
+	name string
+	char rune
+}
+
+var keyAliases = map[string]string{
+	"ctrl-i":    "tab",
+	"ctrl-m":    "enter",
+	"return":    "enter",
+	"ctrl-[":    "esc",
+	"shift-tab": "btab",
+	"bs":        "bspace",
+	"pgup":      "page-up",
+	"pgdn":      "page-down",
+}
+
+// NormalizeKey maps a key name to the canonical form produced by the
+// terminal decoder, resolving fzf aliases such as ctrl-i and tab.
+func NormalizeKey(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := keyAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+var csiKeys = map[string]string{
+	"A":   "up",
+	"B":   "down",
+	"C":   "right",
+	"D":   "left",
+	"H":   "home",
+	"F":   "end",
+	"Z":   "btab",
+	"1~":  "home",
+	"3~":  "del",
+	"4~":  "end",
+	"5~":  "page-up",
+	"6~":  "page-down",
+	"11~": "f1",
+	"12~": "f2",
+	"13~": "f3",
+	"14~": "f4",
+	"15~": "f5",
+	"17~": "f6",
+	"18~": "f7",
+	"19~": "f8",
+	"20~": "f9",
+	"21~": "f10",
+	"23~": "f11",
+	"24~": "f12",
+}
+
+var ss3Keys = map[byte]string{
+	'A': "up",
+	'B': "down",
+	'C': "right",
+	'D': "left",
+	'H': "home",
+	'F': "end",
+	'P': "f1",
+	'Q': "f2",
+	'R': "f3",
+	'S': "f4",
+}
+
+// decodeKeys splits a chunk read from the terminal into keypresses.
+func decodeKeys(buf []byte) []key {
+	var keys []key
+	for len(buf) > 0 {
+		k, n := decodeKey(buf)
+		if n == 0 {
+			break
+		}
+		if k.name != "" || k.char != 0 {
+			keys = append(keys, k)
+		}
+		buf = buf[n:]
+	}
+	return keys
+}
+
+func decodeKey(buf []byte) (key, int) {
+	b := buf[0]
+	switch {
+	case b == 0x1b:
+		return decodeEscape(buf)
+	case b == '\r':
+		return key{name: "enter"}, 1
+	case b == '\t':
+		return key{name: "tab"}, 1
+	case b == 0x7f:
+		return key{name: "bspace"}, 1
+	case b == 0:
+		return key{name: "ctrl-space"}, 1
+	case b < 0x20:
+		return key{name: "ctrl-" + string(rune('a'+b-1))}, 1
+	}
+	r, n := utf8.DecodeRune(buf)
+	if r == utf8.RuneError {
+		return key{}, max(n, 1)
+	}
+	return key{char: r}, n
+}
+
+func decodeEscape(buf []byte) (key, int) {
+	if len(buf) == 1 {
+		return key{name: "esc"}, 1
+	}
+	switch buf[1] {
+	case '[':
+		for i := 2; i < len(buf); i++ {
+			if buf[i] >= 0x40 && buf[i] <= 0x7e {
+				seq := string(buf[2 : i+1])
+				// Drop modifier parameters such as "1;5A" that we do not bind.
+				if idx := strings.Index(seq, ";"); idx >= 0 {
+					final := seq[len(seq)-1:]
+					if param := seq[:idx]; param == "1" {
+						seq = final
+					} else {
+						seq = param + final
+					}
+				}
+				return key{name: csiKeys[seq]}, i + 1
+			}
+		}
+		return key{}, len(buf)
+	case 'O':
+		if len(buf) < 3 {
+			return key{name: "esc"}, len(buf)
+		}
+		return key{name: ss3Keys[buf[2]]}, 3
+	case 0x7f:
+		return key{name: "alt-bspace"}, 2
+	case '\r':
+		return key{name: "alt-enter"}, 2
+	}
+	r, n := utf8.DecodeRune(buf[1:])
+	if r == utf8.RuneError {
+		return key{name: "esc"}, 1
+	}
+	if r < 0x20 {
+		return key{name: "ctrl-alt-" + string(rune('a'+r-1))}, n + 1
+	}
+	return key{name: "alt-" + string(r)}, n + 1
+}
@@ -0,0 +1,100 @@
+package picker
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	scoreMatch       = 16
+	bonusBoundary    = 8
+	bonusConsecutive = 6
+	bonusFirstChar   = 4
+	penaltyGap       = 2
+)
+
+// Match reports whether every space separated term of query fuzzily matches
+// text, returning a score (higher is better) and the matched rune positions.
+// Matching is case-insensitive unless the query contains an upper case letter.
+func Match(query string, text string) (int, []int, bool) {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return 0, nil, true
+	}
+	caseSensitive := strings.IndexFunc(query, unicode.IsUpper) >= 0
+
+	runes := []rune(text)
+	total := 0
+	var positions []int
+	for _, term := range terms {
+		score, pos, ok := matchTerm([]rune(term), runes, caseSensitive)
+		if !ok {
+			return 0, nil, false
+		}
+		total += score
+		positions = append(positions, pos...)
+	}
+	return total, positions, true
+}
+
+func matchTerm(term []rune, text []rune, caseSensitive bool) (int, []int, bool) {
+	fold := func(r rune) rune {
+		if caseSensitive {
+			return r
+		}
+		return unicode.ToLower(r)
+	}
+
+	positions := make([]int, 0, len(term))
+	ti := 0
+	for i := 0; i < len(text) && ti < len(term); i++ {
+		if fold(text[i]) == fold(term[ti]) {
+			positions = append(positions, i)
+			ti++
+		}
+	}
+	if ti < len(term) {
+		return 0, nil, false
+	}
+
+	// Walk backwards from the end of the forward match to find the tightest
+	// window ending there, the same trick fzf's v1 algorithm uses.
+	end := positions[len(positions)-1]
+	ti = len(term) - 1
+	start := end
+	for i := end; i >= 0 && ti >= 0; i-- {
+		if fold(text[i]) == fold(term[ti]) {
+			positions[ti] = i
+			start = i
+			ti--
+		}
+	}
+
+	score := 0
+	for k, pos := range positions {
+		score += scoreMatch
+		if pos == 0 {
+			score += bonusFirstChar
+		}
+		if isBoundary(text, pos) {
+			score += bonusBoundary
+		}
+		if k > 0 && positions[k-1] == pos-1 {
+			score += bonusConsecutive
+		}
+	}
+	score -= (end - start + 1 - len(term)) * penaltyGap
+	return score, positions, true
+}
+
+func isBoundary(text []rune, pos int) bool {
+	if pos == 0 {
+		return true
+	}
+	prev := text[pos-1]
+	cur := text[pos]
+	if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+		return true
+	}
+	return unicode.IsLower(prev) && unicode.IsUpper(cur)
+}
@@ -0,0 +1,267 @@
+// Package picker implements a small fuzzy-finding terminal UI used as a
+// drop-in replacement for fzf when the binary is missing or not wanted.
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+var ErrAborted = errors.New("picker aborted")
+
+type Item struct {
+	Text  string
+	Group string
+}
+
+// Bind maps a key to an action name returned in Selection.Action.
+type Bind struct {
+	Key    string
+	Action string
+}
+
+type Options struct {
+	Prompt  string
+	Items   []Item
+	Binds   []Bind
+	Footer  string
+	Preview func(Item) []string
+}
+
+// Selection is the picker's answer. Action is empty when the item was
+// accepted with enter. Item is empty when nothing matched the query.
+type Selection struct {
+	Action string
+	Item   string
+	Query  string
+}
+
+type match struct {
+	index     int
+	score     int
+	positions []int
+}
+
+type state struct {
+	opts    Options
+	binds   map[string]string
+	query   []rune
+	matches []match
+	cursor  int
+	offset  int
+}
+
+var defaultBinds = map[string]string{
+	"enter":     "accept",
+	"esc":       "abort",
+	"ctrl-c":    "abort",
+	"ctrl-g":    "abort",
+	"ctrl-q":    "abort",
+	"up":        "up",
+	"ctrl-p":    "up",
+	"ctrl-k":    "up",
+	"btab":      "up",
+	"down":      "down",
+	"ctrl-n":    "down",
+	"ctrl-j":    "down",
+	"tab":       "down",
+	"page-up":   "page-up",
+	"page-down": "page-down",
+	"home":      "first",
+	"end":       "last",
+	"bspace":    "backward-delete-char",
+	"ctrl-h":    "backward-delete-char",
+	"ctrl-w":    "backward-kill-word",
+	"ctrl-u":    "clear-query",
+}
+
+// Run shows the picker on the controlling terminal and blocks until an item
+// is accepted, a bound action key is pressed or the picker is aborted.
+func Run(opts Options) (Selection, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return Selection{}, fmt.Errorf("failed to open terminal: %v", err)
+	}
+	defer tty.Close()
+
+	oldState, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return Selection{}, fmt.Errorf("failed to set terminal raw mode: %v", err)
+	}
+	defer term.Restore(int(tty.Fd()), oldState)
+
+	fmt.Fprint(tty, "\x1b[?1049h\x1b[H")
+	defer fmt.Fprint(tty, "\x1b[?25h\x1b[?1049l")
+
+	s := newState(opts)
+	s.filter()
+
+	resize := make(chan os.Signal, 1)
+	signal.Notify(resize, syscall.SIGWINCH)
+	defer signal.Stop(resize)
+
+	input := make(chan []byte)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			buf := make([]byte, 256)
+			n, err := tty.Read(buf)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case input <- buf[:n]:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		width, height, err := term.GetSize(int(tty.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		fmt.Fprint(tty, s.render(width, height))
+
+		select {
+		case <-resize:
+			continue
+		case err := <-readErr:
+			return Selection{}, fmt.Errorf("failed to read from terminal: %v", err)
+		case buf := <-input:
+			for _, k := range decodeKeys(buf) {
+				sel, done, err := s.handle(k, height)
+				if done || err != nil {
+					return sel, err
+				}
+			}
+		}
+	}
+}
+
+func newState(opts Options) *state {
+	binds := make(map[string]string, len(defaultBinds)+len(opts.Binds))
+	for k, v := range defaultBinds {
+		binds[k] = v
+	}
+	for _, b := range opts.Binds {
+		if b.Key == "" {
+			continue
+		}
+		binds[NormalizeKey(b.Key)] = "action:" + b.Action
+	}
+	return &state{opts: opts, binds: binds}
+}
+
+// filter recomputes the visible items for the current query. Items keep their
+// group order; within a group better matches come first.
+func (s *state) filter() {
+	query := string(s.query)
+	s.matches = s.matches[:0]
+	for i, item := range s.opts.Items {
+		score, positions, ok := Match(query, item.Text)
+		if !ok {
+			continue
+		}
+		s.matches = append(s.matches, match{index: i, score: score, positions: positions})
+	}
+	if query != "" {
+		groupRank := make(map[string]int)
+		for _, item := range s.opts.Items {
+			if _, ok := groupRank[item.Group]; !ok {
+				groupRank[item.Group] = len(groupRank)
+			}
+		}
+		slices.SortStableFunc(s.matches, func(a, b match) int {
+			ga := groupRank[s.opts.Items[a.index].Group]
+			gb := groupRank[s.opts.Items[b.index].Group]
+			if ga != gb {
+				return ga - gb
+			}
+			return b.score - a.score
+		})
+	}
+	s.cursor = 0
+	s.offset = 0
+}
+
+func (s *state) selected() (Item, bool) {
+	if s.cursor < 0 || s.cursor >= len(s.matches) {
+		return Item{}, false
+	}
+	return s.opts.Items[s.matches[s.cursor].index], true
+}
+
+func (s *state) handle(k key, height int) (Selection, bool, error) {
+	if k.char == ' ' {
+		if _, ok := s.binds["space"]; ok {
+			k = key{name: "space"}
+		}
+	}
+	if k.name == "" {
+		s.query = append(s.query, k.char)
+		s.filter()
+		return Selection{}, false, nil
+	}
+
+	action, ok := s.binds[k.name]
+	if !ok {
+		return Selection{}, false, nil
+	}
+	item, _ := s.selected()
+	page := max(s.listHeight(height)-1, 1)
+
+	switch action {
+	case "accept":
+		if len(s.matches) == 0 {
+			return Selection{}, false, nil
+		}
+		return Selection{Item: item.Text, Query: string(s.query)}, true, nil
+	case "abort":
+		return Selection{}, true, ErrAborted
+	case "up":
+		s.cursor = max(s.cursor-1, 0)
+	case "down":
+		s.cursor = min(s.cursor+1, max(len(s.matches)-1, 0))
+	case "page-up":
+		s.cursor = max(s.cursor-page, 0)
+	case "page-down":
+		s.cursor = min(s.cursor+page, max(len(s.matches)-1, 0))
+	case "first":
+		s.cursor = 0
+	case "last":
+		s.cursor = max(len(s.matches)-1, 0)
+	case "backward-delete-char":
+		if len(s.query) > 0 {
+			s.query = s.query[:len(s.query)-1]
+			s.filter()
+		}
+	case "backward-kill-word":
+		q := strings.TrimRight(string(s.query), " ")
+		if idx := strings.LastIndex(q, " "); idx >= 0 {
+			s.query = []rune(q[:idx+1])
+		} else {
+			s.query = nil
+		}
+		s.filter()
+	case "clear-query":
+		s.query = nil
+		s.filter()
+	default:
+		if name, ok := strings.CutPrefix(action, "action:"); ok {
+			return Selection{Action: name, Item: item.Text, Query: string(s.query)}, true, nil
+		}
+	}
+	return Selection{}, false, nil
+}
@@ -0,0 +1,145 @@
+package picker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	t.Run("Subsequence", func(t *testing.T) {
+		_, positions, ok := Match("gtm", "go-tms")
+		if !ok {
+			t.Fatalf("expected 'gtm' to match 'go-tms'")
+		}
+		if !reflect.DeepEqual(positions, []int{0, 3, 4}) {
+			t.Errorf("expected positions [0 3 4], got %v", positions)
+		}
+
+		if _, _, ok := Match("xyz", "go-tms"); ok {
+			t.Errorf("expected 'xyz' not to match 'go-tms'")
+		}
+	})
+
+	t.Run("SmartCase", func(t *testing.T) {
+		if _, _, ok := Match("tms", "Go-TMS"); !ok {
+			t.Errorf("expected lower case query to match case-insensitively")
+		}
+		if _, _, ok := Match("TMS", "go-tms"); ok {
+			t.Errorf("expected upper case query to match case-sensitively")
+		}
+	})
+
+	t.Run("AllTermsMustMatch", func(t *testing.T) {
+		if _, _, ok := Match("go api", "go-tms"); ok {
+			t.Errorf("expected 'go api' not to match 'go-tms'")
+		}
+		if _, _, ok := Match("go tms", "go-tms"); !ok {
+			t.Errorf("expected 'go tms' to match 'go-tms'")
+		}
+	})
+
+	t.Run("PrefersTightBoundaryMatches", func(t *testing.T) {
+		tight, _, _ := Match("api", "backend-api")
+		loose, _, _ := Match("api", "a-pretty-item")
+		if tight <= loose {
+			t.Errorf("expected 'backend-api' (%d) to score above 'a-pretty-item' (%d)", tight, loose)
+		}
+	})
+}
+
+func TestDecodeKeys(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []key
+	}{
+		{"\x0e", []key{{name: "ctrl-n"}}},
+		{"\t", []key{{name: "tab"}}},
+		{"\r", []key{{name: "enter"}}},
+		{"\x1b", []key{{name: "esc"}}},
+		{"\x1b[A", []key{{name: "up"}}},
+		{"\x1b[1;5B", []key{{name: "down"}}},
+		{"\x1b[5~", []key{{name: "page-up"}}},
+		{"\x1bx", []key{{name: "alt-x"}}},
+		{"ab", []key{{char: 'a'}, {char: 'b'}}},
+		{"é", []key{{char: 'é'}}},
+	}
+	for _, c := range cases {
+		got := decodeKeys([]byte(c.input))
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("decodeKeys(%q) = %v, expected %v", c.input, got, c.expected)
+		}
+	}
+}
+
+func TestStateHandle(t *testing.T) {
+	opts := Options{
+		Items: []Item{
+			{Text: "api", Group: "Active"},
+			{Text: "web", Group: "Active"},
+			{Text: "notes", Group: "Saved"},
+		},
+		Binds: []Bind{
+			{Key: "ctrl-n", Action: "new"},
+			{Key: "ctrl-i", Action: "interactive"},
+		},
+	}
+
+	t.Run("Accept", func(t *testing.T) {
+		s := newState(opts)
+		s.filter()
+		s.handle(key{name: "down"}, 24)
+		sel, done, err := s.handle(key{name: "enter"}, 24)
+		if !done || err != nil {
+			t.Fatalf("expected enter to finish the picker, got done=%v err=%v", done, err)
+		}
+		if sel.Item != "web" || sel.Action != "" {
+			t.Errorf("expected plain selection of 'web', got %+v", sel)
+		}
+	})
+
+	t.Run("BoundActionOverridesDefault", func(t *testing.T) {
+		s := newState(opts)
+		s.filter()
+		for _, c := range "nts" {
+			s.handle(key{char: c}, 24)
+		}
+		sel, done, _ := s.handle(key{name: "ctrl-n"}, 24)
+		if !done || sel.Action != "new" || sel.Item != "notes" {
+			t.Errorf("expected action 'new' on 'notes', got done=%v %+v", done, sel)
+		}
+	})
+
+	t.Run("AliasedKey", func(t *testing.T) {
+		s := newState(opts)
+		s.filter()
+		sel, done, _ := s.handle(key{name: "tab"}, 24)
+		if !done || sel.Action != "interactive" {
+			t.Errorf("expected ctrl-i bind to fire on tab, got done=%v %+v", done, sel)
+		}
+	})
+
+	t.Run("Abort", func(t *testing.T) {
+		s := newState(opts)
+		s.filter()
+		_, done, err := s.handle(key{name: "esc"}, 24)
+		if !done || !errors.Is(err, ErrAborted) {
+			t.Errorf("expected esc to abort, got done=%v err=%v", done, err)
+		}
+	})
+
+	t.Run("GroupHeaders", func(t *testing.T) {
+		s := newState(opts)
+		s.filter()
+		rows, cursorRow := s.rows()
+		if len(rows) != 5 {
+			t.Fatalf("expected 3 items and 2 headers, got %d rows", len(rows))
+		}
+		if rows[0].header != "Active" || rows[3].header != "Saved" {
+			t.Errorf("expected headers at rows 0 and 3, got %+v", rows)
+		}
+		if cursorRow != 1 {
+			t.Errorf("expected cursor on row 1, got %d", cursorRow)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package picker
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	styleReset     = "\x1b[0m"
+	styleBold      = "\x1b[1m"
+	styleDim       = "\x1b[2m"
+	styleHighlight = "\x1b[32m"
+	styleCursor    = "\x1b[31m"
+	clearLine      = "\x1b[K"
+	minPreviewCols = 70
+)
+
+type row struct {
+	header string
+	match  int
+}
+
+func (s *state) footerLines() []string {
+	if s.opts.Footer == "" {
+		return nil
+	}
+	return strings.Split(s.opts.Footer, "\n")
+}
+
+// listHeight is the number of rows available for items and group headers.
+func (s *state) listHeight(height int) int {
+	h := height - 2
+	if footer := s.footerLines(); len(footer) > 0 {
+		h -= len(footer) + 1
+	}
+	return max(h, 1)
+}
+
+func (s *state) rows() ([]row, int) {
+	rows := make([]row, 0, len(s.matches))
+	cursorRow := 0
+	lastGroup := ""
+	for i, m := range s.matches {
+		group := s.opts.Items[m.index].Group
+		if group != "" && (i == 0 || group != lastGroup) {
+			rows = append(rows, row{header: group, match: -1})
+		}
+		lastGroup = group
+		if i == s.cursor {
+			cursorRow = len(rows)
+		}
+		rows = append(rows, row{match: i})
+	}
+	return rows, cursorRow
+}
+
+func (s *state) render(width int, height int) string {
+	var b strings.Builder
+	b.WriteString("\x1b[?25l\x1b[H")
+
+	listWidth := width
+	showPreview := s.opts.Preview != nil && width >= minPreviewCols
+	if showPreview {
+		listWidth = width / 2
+	}
+
+	listH := s.listHeight(height)
+	rows, cursorRow := s.rows()
+	if cursorRow < s.offset {
+		s.offset = cursorRow
+	}
+	if cursorRow >= s.offset+listH {
+		s.offset = cursorRow - listH + 1
+	}
+	if s.offset > 0 && s.offset == cursorRow && rows[cursorRow-1].header != "" {
+		s.offset--
+	}
+
+	var previewLines []string
+	if showPreview {
+		if item, ok := s.selected(); ok {
+			previewLines = s.opts.Preview(item)
+		}
+	}
+
+	lines := make([]string, 0, height)
+	lines = append(lines, truncate(s.opts.Prompt+string(s.query), listWidth))
+	info := fmt.Sprintf("  %d/%d ", len(s.matches), len(s.opts.Items))
+	lines = append(lines, styleDim+info+strings.Repeat("─", max(listWidth-len(info)-1, 0))+styleReset)
+	for i := 0; i < listH; i++ {
+		idx := s.offset + i
+		if idx >= len(rows) {
+			lines = append(lines, "")
+			continue
+		}
+		lines = append(lines, s.renderRow(rows[idx], listWidth))
+	}
+	if footer := s.footerLines(); len(footer) > 0 {
+		lines = append(lines, styleDim+strings.Repeat("─", max(listWidth-1, 0))+styleReset)
+		for _, l := range footer {
+			lines = append(lines, styleDim+truncate(l, listWidth)+styleReset)
+		}
+	}
+
+	for i, l := range lines {
+		if i >= height {
+			break
+		}
+		b.WriteString(l)
+		b.WriteString(styleReset)
+		b.WriteString(clearLine)
+		if showPreview {
+			p := ""
+			if i < len(previewLines) {
+				p = previewLines[i]
+			}
+			fmt.Fprintf(&b, "\x1b[%dG%s│%s %s", listWidth+1, styleDim, styleReset,
+				truncate(p, width-listWidth-3))
+		}
+		if i < height-1 {
+			b.WriteString("\r\n")
+		}
+	}
+
+	fmt.Fprintf(&b, "\x1b[1;%dH\x1b[?25h", min(len([]rune(s.opts.Prompt))+len(s.query)+1, listWidth))
+	return b.String()
+}
+
+func (s *state) renderRow(r row, width int) string {
+	if r.header != "" {
+		return styleDim + styleBold + truncate("── "+r.header+" ", width) + styleReset
+	}
+	m := s.matches[r.match]
+	text := []rune(s.opts.Items[m.index].Text)
+
+	highlighted := make(map[int]bool, len(m.positions))
+	for _, p := range m.positions {
+		highlighted[p] = true
+	}
+
+	var b strings.Builder
+	if r.match == s.cursor {
+		b.WriteString(styleCursor + styleBold + "> " + styleReset + styleBold)
+	} else {
+		b.WriteString("  ")
+	}
+	limit := max(width-3, 0)
+	for i, c := range text {
+		if i >= limit {
+			break
+		}
+		if highlighted[i] {
+			b.WriteString(styleHighlight + string(c) + "\x1b[39m")
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	b.WriteString(styleReset)
+	return b.String()
+}
+
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if width <= 0 {
+		return ""
+	}
+	if len(r) > width-1 {
+		return string(r[:max(width-1, 0)])
+	}
+	return s
+}
@@ -0,0 +1,246 @@
+// Package plugin runs go-tms-<name> executables found on PATH, the way git
+// runs its subcommands. Plugins receive a JSON Context on stdin and may
+// register picker actions and session sources with a manifest.
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/session"
+	"gopkg.in/yaml.v3"
+)
+
+// Prefix starts the names of plugin executables.
+const Prefix = "go-tms-"
+
+// Context describes the go-tms environment to a plugin.
+type Context struct {
+	ConfigPath string `json:"config_path"`
+	StorePath  string `json:"store_path"`
+	// Session is the tmux session of the client go-tms runs in, if any.
+	Session    string `json:"session"`
+	TmuxSocket string `json:"tmux_socket"`
+	// Selected is the picker entry an action runs for.
+	Selected *Selected `json:"selected,omitempty"`
+}
+
+type Selected struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// Manifest registers a plugin's picker actions and session sources. It is
+// read from plugins/<name>.yaml next to the config file.
+type Manifest struct {
+	Name    string   `yaml:"-"`
+	Actions []Action `yaml:"actions"`
+	Sources []Source `yaml:"sources"`
+}
+
+// Action runs the plugin with Args when Key is pressed in the picker.
+type Action struct {
+	Key    string   `yaml:"key"`
+	Label  string   `yaml:"label"`
+	Args   []string `yaml:"args"`
+	Reload bool     `yaml:"reload"`
+}
+
+// Source runs the plugin with Args to list picker entries, printed as a JSON
+// array of {"name": ..., "path": ...} objects.
+type Source struct {
+	Label string   `yaml:"label"`
+	Args  []string `yaml:"args"`
+}
+
+type entry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// Find returns the executable of the named plugin.
+func Find(name string) (string, error) {
+	if name == "" || strings.ContainsRune(name, filepath.Separator) {
+		return "", fmt.Errorf("invalid plugin name: %q", name)
+	}
+	return exec.LookPath(Prefix + name)
+}
+
+// List returns the names of the plugins on PATH.
+func List() []string {
+	var names []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, ok := strings.CutPrefix(e.Name(), Prefix)
+			if !ok || name == "" || slices.Contains(names, name) {
+				continue
+			}
+			if _, err := Find(name); err == nil {
+				names = append(names, name)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func command(name string, args []string, ctx Context) (*exec.Cmd, error) {
+	path, err := Find(name)
+	if err != nil {
+		return nil, err
+	}
+	input, err := json.Marshal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	return cmd, nil
+}
+
+// Run runs the plugin in the terminal of go-tms.
+func Run(name string, args []string, ctx Context) error {
+	cmd, err := command(name, args, ctx)
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("plugin %s failed: %v", name, err)
+	}
+	return nil
+}
+
+// Output runs the plugin and returns what it printed.
+func Output(name string, args []string, ctx Context) ([]byte, error) {
+	cmd, err := command(name, args, ctx)
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s failed: %v", name, err)
+	}
+	return output, nil
+}
+
+// ManifestDir returns the directory plugin manifests are read from.
+func ManifestDir() (string, error) {
+	path, err := config.Path()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "plugins"), nil
+}
+
+// LoadManifests reads the manifests of the plugins on PATH. Manifests of
+// plugins that are not installed are ignored.
+func LoadManifests() ([]Manifest, error) {
+	dir, err := ManifestDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var manifests []Manifest
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".yaml")
+		if _, err := Find(name); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		manifest := Manifest{Name: name}
+		if err := yaml.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to parse plugin manifest %s: %v", path, err)
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests, nil
+}
+
+// Actions converts the actions of the manifests to picker actions. Actions
+// without a key or bound to a key that is already taken are returned as
+// problems instead.
+func Actions(manifests []Manifest, taken []string) ([]config.Action, []string) {
+	var actions []config.Action
+	var problems []string
+	taken = slices.Clone(taken)
+	for _, m := range manifests {
+		for _, a := range m.Actions {
+			switch {
+			case a.Key == "":
+				problems = append(problems, fmt.Sprintf("plugin %s: action %q has no key", m.Name, a.Label))
+				continue
+			case slices.Contains(taken, a.Key):
+				problems = append(problems, fmt.Sprintf("plugin %s: %s is already bound", m.Name, a.Key))
+				continue
+			}
+			taken = append(taken, a.Key)
+			label := a.Label
+			if label == "" {
+				label = m.Name
+			}
+			actions = append(actions, config.Action{Key: a.Key, Label: label, Reload: a.Reload, Plugin: m.Name, Args: a.Args})
+		}
+	}
+	return actions, problems
+}
+
+// Sources returns the session sources of the manifests, labeled with the
+// plugin's name unless they have a label.
+func Sources(manifests []Manifest, ctx Context) []session.Source {
+	var sources []session.Source
+	for _, m := range manifests {
+		for _, src := range m.Sources {
+			label := src.Label
+			if label == "" {
+				label = m.Name
+			}
+			sources = append(sources, session.NewSource(label, func([]session.Session) ([]session.Session, error) {
+				return list(m.Name, src.Args, label, ctx)
+			}))
+		}
+	}
+	return sources
+}
+
+func list(name string, args []string, label string, ctx Context) ([]session.Session, error) {
+	output, err := Output(name, args, ctx)
+	if err != nil {
+		return nil, err
+	}
+	var entries []entry
+	if err := json.Unmarshal(output, &entries); err != nil {
+		return nil, fmt.Errorf("plugin %s: invalid source output: %v", name, err)
+	}
+	var sessions []session.Session
+	for _, e := range entries {
+		if e.Name == "" || e.Path == "" {
+			continue
+		}
+		sessions = append(sessions, session.Session{
+			Name:        session.SanitizeName(e.Name),
+			CurrentPath: session.ExpandPath(e.Path),
+			Source:      label,
+		})
+	}
+	return sessions, nil
+}
@@ -0,0 +1,103 @@
+package plugin
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/session"
+)
+
+func TestPlugins(t *testing.T) {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	manifests := filepath.Join(dir, "plugins")
+	for _, d := range []string{bin, manifests} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv(config.PathEnv, filepath.Join(dir, "config.yaml"))
+
+	script := `#!/bin/sh
+case "$1" in
+sessions) echo '[{"name": "TICKET-1 fix", "path": "/src/api"}, {"name": "", "path": "/src"}]' ;;
+*) cat > "$(dirname "$0")/context.json" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(bin, Prefix+"jira"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"jira.yaml": `
+actions:
+  - key: alt-j
+    label: ticket
+    args: [open]
+  - key: ctrl-n
+    args: [new]
+sources:
+  - label: Tickets
+    args: [sessions]
+`,
+		// Manifests of plugins that are not installed are ignored.
+		"vpn.yaml": "actions: [{key: alt-v}]\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(manifests, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := List(); !slices.Equal(got, []string{"jira"}) {
+		t.Errorf("List() = %v, expected [jira]", got)
+	}
+	loaded, err := LoadManifests()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 1 || loaded[0].Name != "jira" {
+		t.Fatalf("expected only the jira manifest, got %+v", loaded)
+	}
+
+	actions, problems := Actions(loaded, []string{"ctrl-n"})
+	if len(actions) != 1 || actions[0].Key != "alt-j" || actions[0].Plugin != "jira" || !slices.Equal(actions[0].Args, []string{"open"}) {
+		t.Errorf("unexpected actions %+v", actions)
+	}
+	if len(problems) != 1 {
+		t.Errorf("expected the ctrl-n action to be rejected, got %v", problems)
+	}
+
+	sources := Sources(loaded, Context{})
+	if len(sources) != 1 || sources[0].Label() != "Tickets" {
+		t.Fatalf("expected the Tickets source, got %v", sources)
+	}
+	sessions, err := sources[0].List(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := session.Session{Name: "TICKET-1_fix", CurrentPath: "/src/api", Source: "Tickets"}
+	if len(sessions) != 1 || sessions[0].Name != expected.Name || sessions[0].CurrentPath != expected.CurrentPath || sessions[0].Source != expected.Source {
+		t.Errorf("Sessions() = %+v, expected [%+v]", sessions, expected)
+	}
+
+	ctx := Context{ConfigPath: "/cfg.yaml", Session: "api", Selected: &Selected{Name: "web", Path: "/src/web"}}
+	if err := Run("jira", []string{"open"}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(bin, "context.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Context
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.ConfigPath != ctx.ConfigPath || got.Session != ctx.Session || got.Selected == nil || *got.Selected != *ctx.Selected {
+		t.Errorf("plugin received %+v, expected %+v", got, ctx)
+	}
+}
@@ -0,0 +1,233 @@
+// Package projects discovers project directories to open as new sessions,
+// by scanning configured search roots and by asking zoxide.
+package projects
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/session"
+	"gopkg.in/yaml.v3"
+)
+
+type Finder struct {
+	Roots    []string
+	MaxDepth int
+	Ignore   []string
+	Markers  []string
+}
+
+type cache struct {
+	Key      string    `yaml:"key"`
+	Scanned  time.Time `yaml:"scanned"`
+	Projects []string  `yaml:"projects"`
+}
+
+func NewFinder(cfg *config.Config) Finder {
+	return Finder{
+		Roots:    splitList(cfg.ProjectRoots),
+		MaxDepth: cfg.ProjectMaxDepth,
+		Ignore:   splitList(cfg.ProjectIgnore),
+		Markers:  splitList(cfg.ProjectMarkers),
+	}
+}
+
+func splitList(list string) []string {
+	var items []string
+	for item := range strings.SplitSeq(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Scan walks the search roots and returns every directory containing one of
+// the markers. Projects are not descended into, and neither are directories
+// whose name matches an ignore pattern.
+func (f Finder) Scan() ([]string, error) {
+	var projects []string
+	for _, root := range f.Roots {
+		root, err := session.ExpandHome(root)
+		if err != nil {
+			return nil, err
+		}
+		root = filepath.Clean(root)
+		if _, err := os.Stat(root); err != nil {
+			continue
+		}
+		rootDepth := strings.Count(root, string(filepath.Separator))
+
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == root {
+					return err
+				}
+				return fs.SkipDir
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if path != root && f.ignored(d.Name()) {
+				return fs.SkipDir
+			}
+			if f.isProject(path) {
+				projects = append(projects, path)
+				return fs.SkipDir
+			}
+			if strings.Count(path, string(filepath.Separator))-rootDepth >= f.MaxDepth {
+				return fs.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return projects, nil
+}
+
+func (f Finder) ignored(name string) bool {
+	for _, pattern := range f.Ignore {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Finder) isProject(path string) bool {
+	for _, marker := range f.Markers {
+		if _, err := os.Stat(filepath.Join(path, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Finder) cacheKey() string {
+	return strings.Join([]string{
+		strings.Join(f.Roots, ","),
+		strings.Join(f.Ignore, ","),
+		strings.Join(f.Markers, ","),
+		strconv.Itoa(f.MaxDepth),
+	}, "|")
+}
+
+func getCachePath() (string, error) {
+	sessionStorePath, err := session.GetSessionStorePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(sessionStorePath), "projects.yaml"), nil
+}
+
+// Find returns the discovered projects, reusing the cached scan while it is
+// younger than ttl and was produced with the same finder settings.
+func (f Finder) Find(ttl time.Duration) ([]string, error) {
+	if len(f.Roots) == 0 {
+		return nil, nil
+	}
+	cachePath, err := getCachePath()
+	if err != nil {
+		return nil, err
+	}
+	if c, err := loadCache(cachePath); err == nil {
+		if c.Key == f.cacheKey() && time.Since(c.Scanned) < ttl {
+			return c.Projects, nil
+		}
+	}
+
+	projects, err := f.Scan()
+	if err != nil {
+		return nil, err
+	}
+	_ = saveCache(cachePath, cache{Key: f.cacheKey(), Scanned: time.Now(), Projects: projects})
+	return projects, nil
+}
+
+func loadCache(path string) (cache, error) {
+	var c cache
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+	err = yaml.Unmarshal(data, &c)
+	return c, err
+}
+
+func saveCache(path string, c cache) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Zoxide lists the directories known to zoxide, best ranked first. It
+// returns nothing when zoxide is not installed.
+func Zoxide() ([]string, error) {
+	if _, err := exec.LookPath("zoxide"); err != nil {
+		return nil, nil
+	}
+	output, err := exec.Command("zoxide", "query", "--list").Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var dirs []string
+	for line := range strings.SplitSeq(strings.TrimSpace(string(output)), "\n") {
+		if line != "" {
+			dirs = append(dirs, line)
+		}
+	}
+	return dirs, nil
+}
+
+// Directories merges zoxide results with discovered projects, zoxide's
+// ranking first, without duplicates.
+func Directories(cfg *config.Config) ([]string, error) {
+	zoxideDirs, err := Zoxide()
+	if err != nil {
+		return nil, err
+	}
+	found, err := NewFinder(cfg).Find(time.Duration(cfg.ProjectCacheMinutes) * time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(zoxideDirs)+len(found))
+	dirs := make([]string, 0, len(zoxideDirs)+len(found))
+	for _, dir := range append(zoxideDirs, found...) {
+		dir = filepath.Clean(dir)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// Source offers the projects found under the configured roots.
+func Source(cfg *config.Config) session.Source {
+	return session.NewDirectorySource("Projects", func() ([]string, error) {
+		return NewFinder(cfg).Find(time.Duration(cfg.ProjectCacheMinutes) * time.Minute)
+	})
+}
+
+// ZoxideSource offers the directories known to zoxide.
+func ZoxideSource() session.Source {
+	return session.NewDirectorySource("Zoxide", Zoxide)
+}
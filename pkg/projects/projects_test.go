@@ -0,0 +1,60 @@
+package projects
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	root := t.TempDir()
+	mkdir := func(parts ...string) {
+		if err := os.MkdirAll(filepath.Join(append([]string{root}, parts...)...), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	touch := func(parts ...string) {
+		if err := os.WriteFile(filepath.Join(append([]string{root}, parts...)...), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mkdir("go-tms", ".git")
+	mkdir("go-tms", "pkg", "nested", ".git")
+	mkdir("work", "api")
+	touch("work", "api", "go.mod")
+	mkdir("work", "web", "node_modules", "dep")
+	touch("work", "web", "node_modules", "dep", "package.json")
+	mkdir("deep", "a", "b", "c")
+	touch("deep", "a", "b", "c", "go.mod")
+	mkdir("notes")
+
+	finder := Finder{
+		Roots:    []string{root},
+		MaxDepth: 3,
+		Ignore:   []string{"node_modules"},
+		Markers:  []string{".git", "go.mod", "package.json"},
+	}
+	found, err := finder.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+
+	expected := []string{
+		filepath.Join(root, "go-tms"),
+		filepath.Join(root, "work", "api"),
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("Scan did not find the expected projects.\nExpected: %v\nGot:      %v", expected, found)
+	}
+
+	finder.MaxDepth = 4
+	found, err = finder.Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(found) != 3 {
+		t.Errorf("expected deeper scan to also find deep/a/b/c, got %v", found)
+	}
+}
@@ -0,0 +1,102 @@
+package session
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pattern matches a session name or path. Patterns are globs unless they
+// start with "re:"; a path glob ending in "/**" also matches everything
+// below the directory.
+type pattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+func parsePatterns(list string, paths bool) ([]pattern, error) {
+	var patterns []pattern
+	for _, p := range strings.Split(list, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if expr, ok := strings.CutPrefix(p, "re:"); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid session filter %q: %v", p, err)
+			}
+			patterns = append(patterns, pattern{re: re})
+			continue
+		}
+		if paths {
+			p = ExpandPath(p)
+		}
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid session filter %q: %v", p, err)
+		}
+		patterns = append(patterns, pattern{glob: p})
+	}
+	return patterns, nil
+}
+
+func (p pattern) match(s string) bool {
+	if p.re != nil {
+		return p.re.MatchString(s)
+	}
+	if dir, ok := strings.CutSuffix(p.glob, "/**"); ok {
+		if _, below := TrimPathPrefix(s, dir); below {
+			return true
+		}
+	}
+	ok, _ := filepath.Match(p.glob, s)
+	return ok
+}
+
+func matchAny(patterns []pattern, s string) bool {
+	for _, p := range patterns {
+		if p.match(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter decides which tmux sessions are listed and saved. A session is
+// skipped when its name or path matches an exclude rule and neither matches
+// an include rule.
+type Filter struct {
+	includeNames []pattern
+	excludeNames []pattern
+	includePaths []pattern
+	excludePaths []pattern
+}
+
+// NewFilter parses comma separated name and path patterns.
+func NewFilter(includeNames, excludeNames, includePaths, excludePaths string) (*Filter, error) {
+	var f Filter
+	var err error
+	if f.includeNames, err = parsePatterns(includeNames, false); err != nil {
+		return nil, err
+	}
+	if f.excludeNames, err = parsePatterns(excludeNames, false); err != nil {
+		return nil, err
+	}
+	if f.includePaths, err = parsePatterns(includePaths, true); err != nil {
+		return nil, err
+	}
+	if f.excludePaths, err = parsePatterns(excludePaths, true); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Skip reports whether the session with the given name and path is
+// filtered out.
+func (f *Filter) Skip(name string, path string) bool {
+	if matchAny(f.includeNames, name) || matchAny(f.includePaths, path) {
+		return false
+	}
+	return matchAny(f.excludeNames, name) || matchAny(f.excludePaths, path)
+}
@@ -0,0 +1,226 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	OrderFrecency     = "frecency"
+	OrderRecency      = "recency"
+	OrderAlphabetical = "alphabetical"
+)
+
+// Access records how often and how recently a session was switched to.
+type Access struct {
+	Name     string    `yaml:"name"`
+	Count    int       `yaml:"count"`
+	LastUsed time.Time `yaml:"last-used"`
+}
+
+type History struct {
+	Entries []Access `yaml:"entries"`
+}
+
+func GetHistoryPath() (string, error) {
+	sessionStorePath, err := GetSessionStorePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(sessionStorePath), "history.yaml"), nil
+}
+
+func LoadHistory() (History, error) {
+	historyPath, err := GetHistoryPath()
+	if err != nil {
+		return History{}, err
+	}
+
+	file, err := os.Open(historyPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return History{}, nil
+		}
+		return History{}, err
+	}
+	defer file.Close()
+
+	var history History
+	if err = yaml.NewDecoder(file).Decode(&history); err != nil {
+		return History{}, err
+	}
+	return history, nil
+}
+
+func SaveHistory(history History) error {
+	historyPath, err := GetHistoryPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(historyPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	yamlEncoder := yaml.NewEncoder(file)
+	yamlEncoder.SetIndent(2)
+	return yamlEncoder.Encode(history)
+}
+
+// RecordSwitch notes a switch from one session to another. The session being
+// left is touched without counting as a visit so it becomes the previous one.
+func RecordSwitch(from string, to string) error {
+	history, err := LoadHistory()
+	if err != nil {
+		return err
+	}
+	history.Touch(from, time.Now())
+	history.Visit(to, time.Now().Add(time.Millisecond))
+	return SaveHistory(history)
+}
+
+func (h *History) entry(name string) *Access {
+	for i := range h.Entries {
+		if h.Entries[i].Name == name {
+			return &h.Entries[i]
+		}
+	}
+	h.Entries = append(h.Entries, Access{Name: name})
+	return &h.Entries[len(h.Entries)-1]
+}
+
+// Visit counts an access to the session at the given time.
+func (h *History) Visit(name string, at time.Time) {
+	if name == "" {
+		return
+	}
+	e := h.entry(name)
+	e.Count++
+	e.LastUsed = at
+}
+
+// Touch updates the last use of the session without counting a visit.
+func (h *History) Touch(name string, at time.Time) {
+	if name == "" {
+		return
+	}
+	h.entry(name).LastUsed = at
+}
+
+func (h History) Get(name string) (Access, bool) {
+	for _, e := range h.Entries {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Access{}, false
+}
+
+// Previous returns the most recently used session other than current.
+func (h History) Previous(current string) string {
+	var previous Access
+	for _, e := range h.Entries {
+		if e.Name != current && e.LastUsed.After(previous.LastUsed) {
+			previous = e
+		}
+	}
+	return previous.Name
+}
+
+// Frecency weighs the visit count by how long ago the session was last used,
+// using the same buckets as zoxide.
+func (a Access) Frecency(now time.Time) float64 {
+	age := now.Sub(a.LastUsed)
+	switch {
+	case age < time.Hour:
+		return float64(a.Count) * 4
+	case age < 24*time.Hour:
+		return float64(a.Count) * 2
+	case age < 7*24*time.Hour:
+		return float64(a.Count) * 0.5
+	default:
+		return float64(a.Count) * 0.25
+	}
+}
+
+// SortSessions orders sessions for the picker. For recency and frecency the
+// previous session comes first and the current one last, so the default
+// selection jumps back to where the user came from.
+func SortSessions(sessions []Session, history History, order string, current string, now time.Time) {
+	byName := func(a, b Session) int {
+		return strings.Compare(a.Name, b.Name)
+	}
+	defer sortPinned(sessions)
+
+	switch order {
+	case OrderAlphabetical:
+		slices.SortStableFunc(sessions, byName)
+		return
+	case OrderRecency:
+		slices.SortStableFunc(sessions, func(a, b Session) int {
+			ea, _ := history.Get(a.Name)
+			eb, _ := history.Get(b.Name)
+			if c := eb.LastUsed.Compare(ea.LastUsed); c != 0 {
+				return c
+			}
+			return byName(a, b)
+		})
+	case OrderFrecency:
+		slices.SortStableFunc(sessions, func(a, b Session) int {
+			ea, _ := history.Get(a.Name)
+			eb, _ := history.Get(b.Name)
+			fa, fb := ea.Frecency(now), eb.Frecency(now)
+			if fa != fb {
+				if fa > fb {
+					return -1
+				}
+				return 1
+			}
+			if c := eb.LastUsed.Compare(ea.LastUsed); c != 0 {
+				return c
+			}
+			return byName(a, b)
+		})
+	default:
+		return
+	}
+
+	previous := history.Previous(current)
+	slices.SortStableFunc(sessions, func(a, b Session) int {
+		return rank(a.Name, previous, current) - rank(b.Name, previous, current)
+	})
+}
+
+func rank(name string, previous string, current string) int {
+	switch name {
+	case previous:
+		return -1
+	case current:
+		return 1
+	}
+	return 0
+}
+
+// sortPinned moves pinned sessions to the top, keeping the order otherwise.
+func sortPinned(sessions []Session) {
+	slices.SortStableFunc(sessions, func(a, b Session) int {
+		switch {
+		case a.Pinned == b.Pinned:
+			return 0
+		case a.Pinned:
+			return -1
+		}
+		return 1
+	})
+}
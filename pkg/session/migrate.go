@@ -0,0 +1,68 @@
+package session
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// legacyFiles are the files older versions kept in ~/.tmux/go-tms.
+var legacyFiles = []string{storeFileName, "history.yaml", "projects.yaml"}
+
+// MigrateStore moves the session store and the files next to it from the
+// legacy ~/.tmux/go-tms directory to the current store location, unless a
+// store already exists there. It returns the files that were moved.
+func MigrateStore() ([]string, error) {
+	storePath, err := GetSessionStorePath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(storePath); !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	legacyDir := filepath.Join(homeDir, ".tmux", "go-tms")
+	if _, err := os.Stat(filepath.Join(legacyDir, storeFileName)); err != nil {
+		return nil, nil
+	}
+
+	storeDir := filepath.Dir(storePath)
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		return nil, err
+	}
+	var moved []string
+	for _, name := range legacyFiles {
+		from := filepath.Join(legacyDir, name)
+		to := filepath.Join(storeDir, name)
+		if name == storeFileName {
+			to = storePath
+		}
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		if err := moveFile(from, to); err != nil {
+			return moved, err
+		}
+		moved = append(moved, to)
+	}
+	return moved, nil
+}
+
+// moveFile renames from to to, copying when they are on different devices.
+func moveFile(from string, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(to, data, 0644); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
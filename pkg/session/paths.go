@@ -0,0 +1,139 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type pathRoot struct {
+	name string
+	path string
+}
+
+// pathRoots are the configured path variables, longest path first so the
+// most specific root wins when contracting.
+var pathRoots []pathRoot
+
+// SetPathRoots configures the variables paths are stored relative to, such
+// as PROJECTS=~/projects. Values may use ~ and environment variables.
+func SetPathRoots(roots map[string]string) error {
+	pathRoots = pathRoots[:0]
+	for name, path := range roots {
+		expanded, err := ExpandHome(os.ExpandEnv(path))
+		if err != nil {
+			return err
+		}
+		pathRoots = append(pathRoots, pathRoot{
+			name: strings.TrimPrefix(name, "$"),
+			path: filepath.Clean(expanded),
+		})
+	}
+	slices.SortFunc(pathRoots, func(a, b pathRoot) int {
+		if len(a.path) != len(b.path) {
+			return len(b.path) - len(a.path)
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	return nil
+}
+
+// TrimPathPrefix returns the remainder of path below prefix.
+func TrimPathPrefix(path string, prefix string) (string, bool) {
+	if path == prefix {
+		return "", true
+	}
+	if prefix == "/" {
+		return path[1:], strings.HasPrefix(path, "/")
+	}
+	rest, ok := strings.CutPrefix(path, prefix+"/")
+	return rest, ok
+}
+
+func joinPath(base string, rest string) string {
+	if rest == "" {
+		return base
+	}
+	return strings.TrimSuffix(base, "/") + "/" + rest
+}
+
+// ContractPath rewrites an absolute path relative to the most specific
+// configured root, or to ~ when it lies inside the home directory.
+func ContractPath(path string) string {
+	if path == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	for _, root := range pathRoots {
+		if rest, ok := TrimPathPrefix(path, root.path); ok {
+			return joinPath("$"+root.name, rest)
+		}
+	}
+	if homeDir, err := os.UserHomeDir(); err == nil {
+		if rest, ok := TrimPathPrefix(path, homeDir); ok {
+			return joinPath("~", rest)
+		}
+	}
+	return path
+}
+
+// ExpandPath reverses ContractPath. Unknown variables are taken from the
+// environment and left untouched when unset.
+func ExpandPath(path string) string {
+	if strings.HasPrefix(path, "~") {
+		if expanded, err := ExpandHome(path); err == nil {
+			return expanded
+		}
+		return path
+	}
+	if !strings.HasPrefix(path, "$") {
+		return path
+	}
+	name, rest, _ := strings.Cut(path[1:], "/")
+	name = strings.Trim(name, "{}")
+	for _, root := range pathRoots {
+		if root.name == name {
+			return joinPath(root.path, rest)
+		}
+	}
+	if value, ok := os.LookupEnv(name); ok && value != "" {
+		return joinPath(filepath.Clean(value), rest)
+	}
+	return path
+}
+
+// MapPaths returns a copy of the session with fn applied to every path.
+func (s Session) MapPaths(fn func(string) string) Session {
+	s.CurrentPath = fn(s.CurrentPath)
+	windows := make([]Window, len(s.Windows))
+	for i, w := range s.Windows {
+		w.CurrentPath = fn(w.CurrentPath)
+		panes := make([]Pane, len(w.Panes))
+		for j, p := range w.Panes {
+			p.CurrentPath = fn(p.CurrentPath)
+			panes[j] = p
+		}
+		w.Panes = panes
+		windows[i] = w
+	}
+	if s.Windows == nil {
+		windows = nil
+	}
+	s.Windows = windows
+	return s
+}
+
+// RemapPrefix rewrites paths equal to or below from to live below to.
+func RemapPrefix(from string, to string) func(string) string {
+	from = filepath.Clean(from)
+	to = filepath.Clean(to)
+	return func(path string) string {
+		if path == "" {
+			return path
+		}
+		if rest, ok := TrimPathPrefix(path, from); ok {
+			return joinPath(to, rest)
+		}
+		return path
+	}
+}
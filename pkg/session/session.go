@@ -1,10 +1,14 @@
 package session
 
 import (
+	"errors"
 	"fmt"
+	"github.com/swit33/go-tms/pkg/xdg"
 	"gopkg.in/yaml.v3"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"slices"
 )
 
@@ -12,35 +16,85 @@ type Pane struct {
 	Command     string `yaml:"command"`
 	CurrentPath string `yaml:"workdir"`
 	Index       string `yaml:"index"`
+	// Split is the direction the pane is split off its predecessor, "h" for
+	// side by side or "v" for stacked, and Size its optional -l argument.
+	Split string `yaml:"split,omitempty"`
+	Size  string `yaml:"size,omitempty"`
+	// Commands are sent to the pane after it is created, regardless of the
+	// program whitelist.
+	Commands []string `yaml:"commands,omitempty"`
 }
 
 type Window struct {
-	Panes []Pane `yaml:"panes"`
-	Index string `yaml:"index"`
+	Panes       []Pane `yaml:"panes"`
+	Index       string `yaml:"index"`
+	Name        string `yaml:"name,omitempty"`
+	Layout      string `yaml:"layout,omitempty"`
+	CurrentPath string `yaml:"workdir,omitempty"`
 }
 
 type Session struct {
-	Name        string   `yaml:"name"`
-	Windows     []Window `yaml:"windows"`
-	CurrentPath string   `yaml:"current-path"`
-	TmuxActive  bool     `yaml:"-"`
+	Name        string            `yaml:"name"`
+	Windows     []Window          `yaml:"windows"`
+	CurrentPath string            `yaml:"current-path"`
+	Env         map[string]string `yaml:"env,omitempty"`
+	// Pinned sessions are listed first and never pruned, autostart sessions
+	// are restored on boot and frozen ones keep their saved layout.
+	Pinned     bool `yaml:"pinned,omitempty"`
+	Autostart  bool `yaml:"autostart,omitempty"`
+	Frozen     bool `yaml:"frozen,omitempty"`
+	TmuxActive bool `yaml:"-"`
+	// Source marks picker entries that only describe a directory to open,
+	// such as git worktrees. They are never written to the store.
+	Source string `yaml:"-"`
+}
+
+// SourceWorktree labels the picker entries of git worktrees.
+const SourceWorktree = "Worktrees"
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_/-]`)
+
+// SanitizeName replaces characters tmux does not accept in session names.
+func SanitizeName(name string) string {
+	return invalidNameChars.ReplaceAllString(name, "_")
 }
 
-var sessionStorePath string = filepath.Join(".tmux", "go-tms", "sessions.yaml")
+const storeFileName = "sessions.yaml"
+
+// sessionStorePath overrides the default store location when set.
+var sessionStorePath string
+
+// SetStorePath moves the session store, and the files kept next to it, to
+// path. An empty path selects the default in the XDG state directory.
+func SetStorePath(path string) {
+	sessionStorePath = path
+}
 
 func GetSessionStorePath() (string, error) {
-	homePath, err := os.UserHomeDir()
+	if sessionStorePath != "" {
+		return sessionStorePath, nil
+	}
+	stateHome, err := xdg.StateHome()
 	if err != nil {
 		return "", err
 	}
-	sessionStoreAbsPath := filepath.Join(homePath, sessionStorePath)
-	return sessionStoreAbsPath, nil
+	return filepath.Join(stateHome, "go-tms", storeFileName), nil
 }
 
 func SaveSessionsToDisk(sessions []Session) error {
-	if len(sessions) == 0 {
+	if !slices.ContainsFunc(sessions, func(s Session) bool { return s.Source == "" }) {
 		return nil
 	}
+	return ReplaceSessionsOnDisk(sessions)
+}
+
+// ReplaceSessionsOnDisk writes the sessions to the store like
+// SaveSessionsToDisk, emptying it when none are left, such as after
+// pruning every saved session.
+func ReplaceSessionsOnDisk(sessions []Session) error {
+	sessions = slices.DeleteFunc(slices.Clone(sessions), func(s Session) bool {
+		return s.Source != ""
+	})
 
 	sessionStorePath, err := GetSessionStorePath()
 	if err != nil {
@@ -59,9 +113,14 @@ func SaveSessionsToDisk(sessions []Session) error {
 	}
 	defer file.Close()
 
+	stored := make([]Session, 0, len(sessions))
+	for _, s := range sessions {
+		stored = append(stored, s.MapPaths(ContractPath))
+	}
+
 	yamlEncoder := yaml.NewEncoder(file)
 	yamlEncoder.SetIndent(2)
-	if err = yamlEncoder.Encode(sessions); err != nil {
+	if err = yamlEncoder.Encode(stored); err != nil {
 		return err
 	}
 
@@ -88,6 +147,9 @@ func LoadSessionsFromDisk() ([]Session, error) {
 	if err = yamlDecoder.Decode(&sessions); err != nil {
 		return nil, err
 	}
+	for i := range sessions {
+		sessions[i] = sessions[i].MapPaths(ExpandPath)
+	}
 
 	return sessions, nil
 }
@@ -122,7 +184,19 @@ func CombineSessions(s1 []Session, s2 []Session) ([]Session, error) {
 		return false
 	}
 
+	// Live sessions keep the flags of their saved counterpart. A frozen one
+	// keeps its saved layout so saving never overwrites it.
 	for _, s := range s1 {
+		if saved, err := GetSessionByName(s.Name, s2); err == nil {
+			if saved.Frozen {
+				frozen := *saved
+				frozen.TmuxActive = s.TmuxActive
+				s = frozen
+			}
+			s.Pinned = saved.Pinned
+			s.Autostart = saved.Autostart
+			s.Frozen = saved.Frozen
+		}
 		sessions = append(sessions, s)
 	}
 
@@ -135,6 +209,45 @@ func CombineSessions(s1 []Session, s2 []Session) ([]Session, error) {
 	return sessions, nil
 }
 
+// IsStale reports whether the session's root directory no longer exists.
+func (s Session) IsStale() bool {
+	if s.CurrentPath == "" {
+		return false
+	}
+	_, err := os.Stat(s.CurrentPath)
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// PruneStale splits off saved sessions whose root directory is gone. Pinned
+// sessions and sessions running in tmux are always kept.
+func PruneStale(s []Session) ([]Session, []Session) {
+	kept := make([]Session, 0, len(s))
+	var pruned []Session
+	for _, session := range s {
+		if !session.TmuxActive && !session.Pinned && session.IsStale() {
+			pruned = append(pruned, session)
+			continue
+		}
+		kept = append(kept, session)
+	}
+	return kept, pruned
+}
+
+// Flags describes the per-session flags that are set.
+func (s Session) Flags() []string {
+	var flags []string
+	if s.Pinned {
+		flags = append(flags, "pinned")
+	}
+	if s.Autostart {
+		flags = append(flags, "autostart")
+	}
+	if s.Frozen {
+		flags = append(flags, "frozen")
+	}
+	return flags
+}
+
 func DeleteSession(name string, s []Session) ([]Session, error) {
 	for i, session := range s {
 		if session.Name == name {
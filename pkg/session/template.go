@@ -0,0 +1,165 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectTemplateFile is looked up in the directory of a new session.
+const ProjectTemplateFile = ".go-tms.yaml"
+
+// Template describes the windows and panes of a new session. Paths are
+// relative to the directory the session is created for.
+type Template struct {
+	Session `yaml:",inline"`
+	// Match is a glob of directories the template applies to.
+	Match string `yaml:"match,omitempty"`
+	// File references a tmuxinator, tmuxp or smug project file providing
+	// the windows of the template.
+	File string `yaml:"file,omitempty"`
+}
+
+// UnmarshalYAML accepts a plain string as shorthand for a pane running a
+// single command.
+func (p *Pane) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		p.Commands = []string{node.Value}
+		return nil
+	}
+	type plain Pane
+	return node.Decode((*plain)(p))
+}
+
+// LoadTemplate reads a template from a YAML file.
+func LoadTemplate(path string) (Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Template{}, err
+	}
+	var t Template
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return Template{}, fmt.Errorf("failed to parse template %s: %v", path, err)
+	}
+	t.ResolveFile(filepath.Dir(path))
+	return t, nil
+}
+
+// ResolveFile makes a relative File relative to dir, the directory of the
+// file declaring the template.
+func (t *Template) ResolveFile(dir string) {
+	if t.File != "" && !filepath.IsAbs(t.File) && !strings.HasPrefix(t.File, "~") {
+		t.File = filepath.Join(dir, t.File)
+	}
+}
+
+// FindTemplate returns the template for a new session in dir: the project's
+// own .go-tms.yaml if present and trusted, else the first configured
+// template matching the directory.
+func FindTemplate(dir string, templates []Template) (Template, bool, error) {
+	exists, trusted, err := ProjectTemplateTrusted(dir)
+	if err != nil {
+		return Template{}, false, err
+	}
+	if exists && trusted {
+		t, err := LoadTemplate(filepath.Join(dir, ProjectTemplateFile))
+		if err != nil {
+			return Template{}, false, err
+		}
+		return t, true, nil
+	}
+
+	for _, t := range templates {
+		if t.Match == "" {
+			continue
+		}
+		pattern, err := ExpandHome(t.Match)
+		if err != nil {
+			return Template{}, false, err
+		}
+		if ok, _ := filepath.Match(pattern, filepath.Clean(dir)); ok {
+			return t, true, nil
+		}
+	}
+	return Template{}, false, nil
+}
+
+// GetTemplateByName looks up a configured template by name.
+func GetTemplateByName(name string, templates []Template) (Template, error) {
+	for _, t := range templates {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return Template{}, fmt.Errorf("template not found: %s", name)
+}
+
+// Instantiate turns the template into a session named name rooted at dir,
+// resolving every relative working directory.
+func (t Template) Instantiate(name string, dir string) (Session, error) {
+	root, err := resolvePath(dir, t.CurrentPath)
+	if err != nil {
+		return Session{}, err
+	}
+	s := Session{
+		Name:        name,
+		CurrentPath: root,
+		Env:         t.Env,
+		Windows:     make([]Window, 0, len(t.Windows)),
+	}
+
+	for i, w := range t.Windows {
+		windowRoot, err := resolvePath(root, w.CurrentPath)
+		if err != nil {
+			return Session{}, err
+		}
+		window := w
+		window.CurrentPath = windowRoot
+		window.Index = fmt.Sprint(i + 1)
+		window.Panes = make([]Pane, 0, max(len(w.Panes), 1))
+		panes := w.Panes
+		if len(panes) == 0 {
+			panes = []Pane{{}}
+		}
+		for j, p := range panes {
+			panePath, err := resolvePath(windowRoot, p.CurrentPath)
+			if err != nil {
+				return Session{}, err
+			}
+			p.CurrentPath = panePath
+			p.Index = fmt.Sprint(j + 1)
+			window.Panes = append(window.Panes, p)
+		}
+		s.Windows = append(s.Windows, window)
+	}
+	return s, nil
+}
+
+func resolvePath(base string, path string) (string, error) {
+	if path == "" {
+		return base, nil
+	}
+	path, err := ExpandHome(path)
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path), nil
+	}
+	return filepath.Join(base, path), nil
+}
+
+// ExpandHome replaces a leading ~ with the user's home directory.
+func ExpandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, path[1:]), nil
+}
@@ -0,0 +1,120 @@
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+func GetTrustPath() (string, error) {
+	sessionStorePath, err := GetSessionStorePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(sessionStorePath), "trusted.yaml"), nil
+}
+
+func loadTrusted() (map[string]string, error) {
+	trustPath, err := GetTrustPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(trustPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	trusted := map[string]string{}
+	if err := yaml.Unmarshal(data, &trusted); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", trustPath, err)
+	}
+	return trusted, nil
+}
+
+// hashProjectTemplate hashes a project template file along with the project
+// file its file key references, so editing either one revokes the trust.
+func hashProjectTemplate(projectFile string) (string, error) {
+	data, err := os.ReadFile(projectFile)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(data)
+	var t Template
+	if err := yaml.Unmarshal(data, &t); err == nil && t.File != "" {
+		t.ResolveFile(filepath.Dir(projectFile))
+		path, err := ExpandHome(t.File)
+		if err != nil {
+			return "", err
+		}
+		referenced, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		h.Write(referenced)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ProjectTemplateTrusted reports whether dir has a project template file and
+// whether its current content, and that of the file it references, was
+// trusted. Project files come with the directory, such as a cloned
+// repository, so they are only applied once the user trusted their exact
+// content.
+func ProjectTemplateTrusted(dir string) (bool, bool, error) {
+	projectFile, err := filepath.Abs(filepath.Join(dir, ProjectTemplateFile))
+	if err != nil {
+		return false, false, err
+	}
+	hash, err := hashProjectTemplate(projectFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, false, nil
+	}
+	if err != nil {
+		return true, false, err
+	}
+	trusted, err := loadTrusted()
+	if err != nil {
+		return true, false, err
+	}
+	return true, trusted[projectFile] == hash, nil
+}
+
+// TrustProjectTemplate records the current content of dir's project template
+// file and of the file it references as trusted and returns the file.
+func TrustProjectTemplate(dir string) (string, error) {
+	projectFile, err := filepath.Abs(filepath.Join(dir, ProjectTemplateFile))
+	if err != nil {
+		return "", err
+	}
+	hash, err := hashProjectTemplate(projectFile)
+	if err != nil {
+		return "", err
+	}
+	trusted, err := loadTrusted()
+	if err != nil {
+		return "", err
+	}
+	trusted[projectFile] = hash
+
+	trustPath, err := GetTrustPath()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(trustPath), 0755); err != nil {
+		return "", err
+	}
+	data, err := yaml.Marshal(trusted)
+	if err != nil {
+		return "", err
+	}
+	return projectFile, os.WriteFile(trustPath, data, 0644)
+}
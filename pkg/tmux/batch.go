@@ -0,0 +1,97 @@
+package tmux
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swit33/go-tms/pkg/interfaces"
+	"github.com/swit33/go-tms/pkg/session"
+)
+
+// shellTimeout bounds how long a restore waits for the shells of its panes.
+const shellTimeout = 5 * time.Second
+
+// escapeArg keeps tmux from reading a trailing semicolon as a command
+// separator.
+func escapeArg(arg string) string {
+	if strings.HasSuffix(arg, ";") {
+		return arg[:len(arg)-1] + `\;`
+	}
+	return arg
+}
+
+// BatchArgs chains the steps into the arguments of a single tmux
+// invocation. Each step is followed by a marker printing its position, so a
+// failure can be traced back to the step that caused it; tmux stops at the
+// first command that fails.
+func BatchArgs(steps []Step) []string {
+	var args []string
+	for i, step := range steps {
+		if i > 0 {
+			args = append(args, ";")
+		}
+		for _, arg := range step.Args {
+			args = append(args, escapeArg(arg))
+		}
+		args = append(args, ";", "display-message", "-p", strconv.Itoa(i))
+	}
+	return args
+}
+
+func runBatch(steps []Step, runner interfaces.Runner) error {
+	if len(steps) == 0 {
+		return nil
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("tmux", BatchArgs(steps)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := runner.Run(cmd); err != nil {
+		failed := min(lastMarker(stdout.String())+1, len(steps)-1)
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %s", steps[failed].Failure, msg)
+		}
+		return fmt.Errorf("%s: %v", steps[failed].Failure, err)
+	}
+	return nil
+}
+
+// lastMarker returns the position of the last step a batch completed, or -1.
+// Other output such as pane ids is not numeric.
+func lastMarker(output string) int {
+	last := -1
+	for line := range strings.SplitSeq(output, "\n") {
+		if n, err := strconv.Atoi(strings.TrimSpace(line)); err == nil {
+			last = n
+		}
+	}
+	return last
+}
+
+// waitForShells polls the panes of a freshly created session until each
+// shell has drawn its prompt, giving up after timeout.
+func waitForShells(s *session.Session, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for i, window := range s.Windows {
+		for j := range window.Panes {
+			target := fmt.Sprintf("%s:%d.%d", s.Name, i+1, j+1)
+			for !shellReady(target) && time.Now().Before(deadline) {
+				time.Sleep(50 * time.Millisecond)
+			}
+		}
+	}
+}
+
+// shellReady reports whether the pane's cursor has moved, which happens once
+// its shell prints a prompt.
+func shellReady(target string) bool {
+	output, err := exec.Command("tmux", "display-message", "-p", "-t", target, "#{cursor_x},#{cursor_y}").Output()
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(output)) != "0,0"
+}
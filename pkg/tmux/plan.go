@@ -0,0 +1,143 @@
+package tmux
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/interfaces"
+	"github.com/swit33/go-tms/pkg/session"
+)
+
+// Step is a single tmux invocation of a restore plan.
+type Step struct {
+	Args []string
+	// Failure describes the step in the error returned when it fails.
+	Failure string
+	// Input marks steps typing into a pane, which need its shell to be ready.
+	Input bool
+}
+
+func (st Step) Command() *exec.Cmd {
+	return exec.Command("tmux", st.Args...)
+}
+
+func runStep(st Step, runner interfaces.Runner) error {
+	if err := runner.Run(st.Command()); err != nil {
+		return fmt.Errorf("%s: %v", st.Failure, err)
+	}
+	return nil
+}
+
+// PaneCommands returns what is typed into a pane when it is restored: the
+// whitelisted program it was running followed by its startup commands.
+func PaneCommands(pane session.Pane, cfg *config.Config) []string {
+	var commands []string
+	if pane.Command != "" && slices.Contains(strings.Split(cfg.ProgramWhitelist, ","), pane.Command) {
+		if pane.Command == "nvim" && cfg.NvimCustomCommand != "" {
+			commands = append(commands, cfg.NvimCustomCommand)
+		} else {
+			commands = append(commands, pane.Command)
+		}
+	}
+	return append(commands, pane.Commands...)
+}
+
+// BuildRestorePlan returns the tmux commands that recreate the session. The
+// first step always creates the detached session; switching the client to
+// it is left to the caller.
+func BuildRestorePlan(s *session.Session, cfg *config.Config) []Step {
+	plan := []Step{newSessionStep(s)}
+
+	for i, window := range s.Windows {
+		windowTarget := s.Name + ":" + strconv.Itoa(i+1)
+		if i != 0 {
+			args := []string{"new-window", "-t", windowTarget, "-c", window.Panes[0].CurrentPath}
+			if window.Name != "" {
+				args = append(args, "-n", window.Name)
+			}
+			plan = append(plan, Step{Args: args, Failure: fmt.Sprintf("window %d: failed to create window", i+1)})
+		}
+		for j, pane := range window.Panes {
+			paneTarget := windowTarget + "." + strconv.Itoa(j+1)
+			if j == 0 {
+				if i == 0 {
+					plan = append(plan, Step{
+						Args:    []string{"send-keys", "-t", paneTarget, "cd " + pane.CurrentPath, "C-m"},
+						Failure: fmt.Sprintf("window %d pane %d: failed to set pane path", i+1, j+1),
+						Input:   true,
+					})
+				}
+			} else {
+				args := []string{"split-window"}
+				switch pane.Split {
+				case "h", "horizontal":
+					args = append(args, "-h")
+				case "v", "vertical":
+					args = append(args, "-v")
+				}
+				if pane.Size != "" {
+					args = append(args, "-l", pane.Size)
+				}
+				args = append(args, "-t", windowTarget+"."+strconv.Itoa(j), "-c", pane.CurrentPath)
+				plan = append(plan, Step{Args: args, Failure: fmt.Sprintf("window %d pane %d: failed to split window", i+1, j+1)})
+			}
+			for _, command := range PaneCommands(pane, cfg) {
+				plan = append(plan, Step{
+					Args:    []string{"send-keys", "-t", paneTarget, command, "C-m"},
+					Failure: fmt.Sprintf("window %d pane %d: failed to run %q", i+1, j+1, command),
+					Input:   true,
+				})
+			}
+		}
+		if window.Layout != "" {
+			plan = append(plan, Step{
+				Args:    []string{"select-layout", "-t", windowTarget, window.Layout},
+				Failure: fmt.Sprintf("window %d: failed to select layout", i+1),
+			})
+		}
+	}
+	if cfg.SelectFirst && len(s.Windows) > 1 {
+		plan = append(plan, Step{
+			Args:    []string{"select-window", "-t", s.Name + ":1"},
+			Failure: "window 1: failed to select window",
+		})
+	}
+	return plan
+}
+
+// VerifySteps check that every window and pane of the session exists after
+// its restore plan ran.
+func VerifySteps(s *session.Session) []Step {
+	var steps []Step
+	for i, window := range s.Windows {
+		for j := range window.Panes {
+			steps = append(steps, Step{
+				Args:    []string{"display-message", "-p", "-t", fmt.Sprintf("%s:%d.%d", s.Name, i+1, j+1), "#{pane_id}"},
+				Failure: fmt.Sprintf("window %d pane %d: pane is missing after restore", i+1, j+1),
+			})
+		}
+	}
+	return steps
+}
+
+// newSessionStep creates the detached session with the name of its first
+// window and its environment.
+func newSessionStep(s *session.Session) Step {
+	args := []string{"new-session", "-d", "-s", s.Name, "-c", s.CurrentPath}
+	if len(s.Windows) > 0 && s.Windows[0].Name != "" {
+		args = append(args, "-n", s.Windows[0].Name)
+	}
+	keys := make([]string, 0, len(s.Env))
+	for k := range s.Env {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		args = append(args, "-e", k+"="+s.Env[k])
+	}
+	return Step{Args: args, Failure: "failed to create new session"}
+}
@@ -7,19 +7,36 @@ import (
 	"github.com/swit33/go-tms/pkg/session"
 	"os"
 	"os/exec"
-	"slices"
-	"strconv"
 	"strings"
 )
 
+// StartupSession runs the switcher after a boot. It is never listed or
+// saved, whatever the configured filters.
+const StartupSession = "go-tms-startup"
+
+// SessionFilter builds the filter for the configured include and exclude
+// rules. ignore-home adds the home directory to the excluded paths.
+func SessionFilter(cfg *config.Config) (*session.Filter, error) {
+	excludePaths := cfg.ExcludePaths
+	if cfg.IgnoreHome {
+		excludePaths += ",~"
+	}
+	return session.NewFilter(cfg.IncludeNames, cfg.ExcludeNames, cfg.IncludePaths, excludePaths)
+}
+
 func ListSessions(cfg *config.Config) ([]session.Session, error) {
 	var output []byte
 	var err error
 
+	filter, err := SessionFilter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	cmd := exec.Command("tmux", "list-panes", "-a", "-F", "#{session_name}|#{session_path}|#{window_index}|#{pane_index}|#{pane_current_command}|#{pane_current_path}")
 	output, err = cmd.Output()
 	if err != nil {
-		if strings.Contains(err.Error(), "no server running") {
+		if isNoServerError(err) {
 			return []session.Session{}, nil
 		}
 		return nil, fmt.Errorf("failed to list sessions: %v", err)
@@ -41,22 +58,7 @@ func ListSessions(cfg *config.Config) ([]session.Session, error) {
 		paneCommand := parts[4]
 		panePath := parts[5]
 
-		userHomeDir, err := os.UserHomeDir()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get user home dir: %v", err)
-		}
-
-		if sessionPath == userHomeDir && cfg.IgnoreHome {
-			continue
-		}
-		if sessionPath == "/dev/null" {
-			continue
-		}
-		if sessionPath == "/tmp" {
-			continue
-		}
-
-		if sessionName == "go-tms-startup" {
+		if sessionName == StartupSession || filter.Skip(sessionName, sessionPath) {
 			continue
 		}
 
@@ -100,6 +102,24 @@ func ListSessions(cfg *config.Config) ([]session.Session, error) {
 	return sessions, nil
 }
 
+// isNoServerError reports whether a tmux command failed only because no
+// server is running. tmux prints the reason on stderr, which Output keeps in
+// the ExitError.
+func isNoServerError(err error) bool {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		stderr := string(exitErr.Stderr)
+		return strings.Contains(stderr, "no server running") || strings.Contains(stderr, "error connecting to")
+	}
+	return strings.Contains(err.Error(), "no server running")
+}
+
+// SessionSource lists the running sessions.
+func SessionSource(cfg *config.Config) session.Source {
+	return session.NewSource("Active", func([]session.Session) ([]session.Session, error) {
+		return ListSessions(cfg)
+	})
+}
+
 func CreateNewSession(sessionName string, directory string, runner interfaces.Runner) (string, error) {
 	var cmd *exec.Cmd
 	cmd = exec.Command("tmux", "new-session", "-d", "-s", sessionName, "-c", directory)
@@ -152,65 +172,103 @@ func AttachSession(sessionName string, runner interfaces.Runner) error {
 	return nil
 }
 
+// RestoreSession recreates the session detached and switches to it once all
+// of its windows and panes exist. When a step fails the partially restored
+// session is killed so it cannot shadow the saved one.
 func RestoreSession(s *session.Session, runner interfaces.Runner, cfg *config.Config) error {
-	if _, err := CreateNewSession(s.Name, s.CurrentPath, runner); err != nil {
-		return err
-	}
-	err := SwitchSession(s.Name, runner)
-	if err != nil {
+	if err := RestoreSessionDetached(s, runner, cfg); err != nil {
 		return err
 	}
+	return SwitchSession(s.Name, runner)
+}
 
-	for i, window := range s.Windows {
-		if i != 0 {
-			cmd := exec.Command("tmux", "new-window",
-				"-t", s.Name+":"+strconv.Itoa(i+1), "-c", window.Panes[0].CurrentPath)
-			if err := runner.Run(cmd); err != nil {
-				return fmt.Errorf("failed to create new window: %v", err)
-			}
+// RestoreSessionDetached recreates the session like RestoreSession without
+// switching the client to it.
+func RestoreSessionDetached(s *session.Session, runner interfaces.Runner, cfg *config.Config) error {
+	plan := BuildRestorePlan(s, cfg)
+	if err := runStep(plan[0], runner); err != nil {
+		return fmt.Errorf("failed to restore session %s: %v", s.Name, err)
+	}
+	if err := buildSession(s, plan[1:], runner, cfg); err != nil {
+		killErr := runner.Run(exec.Command("tmux", RollbackArgs(s)...))
+		if killErr != nil {
+			return fmt.Errorf("failed to restore session %s: %v (failed to remove partial session: %v)", s.Name, err, killErr)
 		}
-		for j, pane := range window.Panes {
-			if j == 0 {
-				if i == 0 {
-					cmd := exec.Command("tmux", "send-keys",
-						"-t", s.Name+":"+strconv.Itoa(i+1)+"."+strconv.Itoa(j+1), "cd "+pane.CurrentPath, "C-m")
-					if err := runner.Run(cmd); err != nil {
-						return fmt.Errorf("failed to set pane path: %v", err)
-					}
-				}
-			} else {
-				cmd := exec.Command("tmux", "split-window",
-					"-t", s.Name+":"+strconv.Itoa(i+1)+"."+strconv.Itoa(j), "-c", pane.CurrentPath)
-				if err := runner.Run(cmd); err != nil {
-					return fmt.Errorf("failed to split window: %v", err)
-				}
+		return fmt.Errorf("failed to restore session %s: %v", s.Name, err)
+	}
+	return nil
+}
 
-			}
-			if slices.Contains(strings.Split(cfg.ProgramWhitelist, ","), pane.Command) {
-				var cmd *exec.Cmd
-				if pane.Command == "nvim" && cfg.NvimCustomCommand != "" {
-					cmd = exec.Command("tmux", "send-keys",
-						"-t", s.Name+":"+strconv.Itoa(i+1)+"."+strconv.Itoa(j+1), cfg.NvimCustomCommand, "C-m")
-				} else {
-					cmd = exec.Command("tmux", "send-keys",
-						"-t", s.Name+":"+strconv.Itoa(i+1)+"."+strconv.Itoa(j+1), pane.Command, "C-m")
-				}
-				if err := runner.Run(cmd); err != nil {
-					return fmt.Errorf("failed to run pane command: %v", err)
-				}
-			}
+// buildSession runs the rest of a restore plan in a single tmux invocation.
+// When waiting for shells, the windows and panes are created first and the
+// input is only typed once every pane shows a prompt.
+func buildSession(s *session.Session, steps []Step, runner interfaces.Runner, cfg *config.Config) error {
+	for _, b := range batches(s, steps, cfg) {
+		if b.waitForShells {
+			waitForShells(s, shellTimeout)
 		}
-		// TODO: debug this not always working
-		if cfg.SelectFirst {
-			cmd := exec.Command("tmux", "select-window", "-t", "1")
-			if err := runner.Run(cmd); err != nil {
-				return fmt.Errorf("failed to select window: %v", err)
-			}
+		if err := runBatch(b.steps, runner); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// restoreBatch is a group of restore steps run in a single tmux invocation.
+type restoreBatch struct {
+	steps []Step
+	// waitForShells marks a batch that only runs once every pane shows a
+	// prompt.
+	waitForShells bool
+}
+
+// batches splits the steps following new-session into the tmux invocations
+// of a restore, the first one ending with the checks of VerifySteps.
+func batches(s *session.Session, steps []Step, cfg *config.Config) []restoreBatch {
+	if !cfg.WaitForShell {
+		return []restoreBatch{{steps: append(steps, VerifySteps(s)...)}}
+	}
+	var layout, input []Step
+	for _, step := range steps {
+		if step.Input {
+			input = append(input, step)
+		} else {
+			layout = append(layout, step)
+		}
+	}
+	return []restoreBatch{
+		{steps: append(layout, VerifySteps(s)...)},
+		{steps: input, waitForShells: true},
+	}
+}
+
+// Invocation is a tmux invocation run by a restore.
+type Invocation struct {
+	Args []string
+	// WaitForShells is set when the invocation only runs once every pane
+	// shows a prompt.
+	WaitForShells bool
+}
+
+// RestoreInvocations returns every tmux invocation a restore of the session
+// runs, in order.
+func RestoreInvocations(s *session.Session, cfg *config.Config) []Invocation {
+	plan := BuildRestorePlan(s, cfg)
+	invocations := []Invocation{{Args: plan[0].Args}}
+	for _, b := range batches(s, plan[1:], cfg) {
+		if len(b.steps) > 0 {
+			invocations = append(invocations, Invocation{Args: BatchArgs(b.steps), WaitForShells: b.waitForShells})
+		}
+	}
+	return invocations
+}
+
+// RollbackArgs are the arguments of the tmux invocation removing a
+// partially restored session.
+func RollbackArgs(s *session.Session) []string {
+	return []string{"kill-session", "-t", s.Name}
+}
+
 func CheckIfSessionExists(ispath bool, identifier string) (string, error) {
 	cmd := exec.Command("tmux", "list-sessions", "-F", "#{session_name}|#{session_path}")
 	output, err := cmd.Output()
@@ -240,6 +298,30 @@ func CheckIfSessionExists(ispath bool, identifier string) (string, error) {
 	return "", nil
 }
 
+// CurrentSession returns the name of the session the client is attached to,
+// or an empty string when not running inside tmux.
+func CurrentSession() (string, error) {
+	return clientFormat("#{session_name}")
+}
+
+// LastSession returns the session the client was attached to before the
+// current one.
+func LastSession() (string, error) {
+	return clientFormat("#{client_last_session}")
+}
+
+func clientFormat(format string) (string, error) {
+	if os.Getenv("TMUX") == "" {
+		return "", nil
+	}
+	cmd := exec.Command("tmux", "display-message", "-p", format)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to query tmux client: %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 func DeleteSession(sessionName string) error {
 	cmd := exec.Command("tmux", "kill-session", "-t", sessionName)
 	if err := cmd.Run(); err != nil {
@@ -1,9 +1,14 @@
 package tmux
 
 import (
+	"errors"
+	"fmt"
 	"github.com/swit33/go-tms/pkg/config"
 	"github.com/swit33/go-tms/pkg/interfaces"
 	"github.com/swit33/go-tms/pkg/session"
+	"os/exec"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -57,13 +62,16 @@ var restorationTestCases = []RestorationTestCase{
 		},
 		ExpectedCmd: []string{
 			"tmux new-session -d -s test01 -c /home/aleksej/projects/go-tms",
+			batch(
+				"send-keys -t test01:1.1 cd /home/aleksej/projects/go-tms C-m",
+				"new-window -t test01:2 -c /home/aleksej/projects/go-tms",
+				"split-window -t test01:2.1 -c /home/aleksej/projects/go-tms",
+				"send-keys -t test01:2.2 nvim C-m",
+				"display-message -p -t test01:1.1 #{pane_id}",
+				"display-message -p -t test01:2.1 #{pane_id}",
+				"display-message -p -t test01:2.2 #{pane_id}",
+			),
 			"tmux switch-client -t test01",
-			"tmux send-keys -t 1.1 cd /home/aleksej/projects/go-tms C-m",
-			"tmux send-keys -t 1.1 zsh C-m",
-			"tmux new-window -t 2 -c /home/aleksej/projects/go-tms",
-			"tmux send-keys -t 2.1 zsh C-m",
-			"tmux split-window -t 2.1 -c /home/aleksej/projects/go-tms",
-			"tmux send-keys -t 2.2 nvim C-m",
 		},
 	},
 	{
@@ -95,11 +103,13 @@ var restorationTestCases = []RestorationTestCase{
 		},
 		ExpectedCmd: []string{
 			"tmux new-session -d -s first-window-panes -c /home/aleksej/projects",
+			batch(
+				"send-keys -t first-window-panes:1.1 cd /home/aleksej/projects C-m",
+				"split-window -t first-window-panes:1.1 -c /home/aleksej/projects/go-tms",
+				"display-message -p -t first-window-panes:1.1 #{pane_id}",
+				"display-message -p -t first-window-panes:1.2 #{pane_id}",
+			),
 			"tmux switch-client -t first-window-panes",
-			"tmux send-keys -t 1.1 cd /home/aleksej/projects C-m",
-			"tmux send-keys -t 1.1 zsh C-m",
-			"tmux split-window -t 1.1 -c /home/aleksej/projects/go-tms",
-			"tmux send-keys -t 1.2 npm start C-m",
 		},
 	},
 	{
@@ -124,15 +134,27 @@ var restorationTestCases = []RestorationTestCase{
 		},
 		ExpectedCmd: []string{
 			"tmux new-session -d -s multi-window-paths -c /home/aleksej/projects",
+			batch(
+				"send-keys -t multi-window-paths:1.1 cd /home/aleksej/projects/go-tms C-m",
+				"new-window -t multi-window-paths:2 -c /home/aleksej/projects/backend",
+				"display-message -p -t multi-window-paths:1.1 #{pane_id}",
+				"display-message -p -t multi-window-paths:2.1 #{pane_id}",
+			),
 			"tmux switch-client -t multi-window-paths",
-			"tmux send-keys -t 1.1 cd /home/aleksej/projects/go-tms C-m",
-			"tmux send-keys -t 1.1 zsh C-m",
-			"tmux new-window -t 2 -c /home/aleksej/projects/backend",
-			"tmux send-keys -t 2.1 docker-compose up C-m",
 		},
 	},
 }
 
+// batch returns the single tmux invocation the commands are chained into,
+// each followed by its position marker.
+func batch(commands ...string) string {
+	parts := make([]string, 0, len(commands))
+	for i, cmd := range commands {
+		parts = append(parts, fmt.Sprintf("%s ; display-message -p %d", cmd, i))
+	}
+	return "tmux " + strings.Join(parts, " ; ")
+}
+
 func TestRestoreSession(t *testing.T) {
 	for _, testCase := range restorationTestCases {
 		runner := &interfaces.MockRunner{}
@@ -147,6 +169,7 @@ func TestRestoreSession(t *testing.T) {
 		if len(runner.ExecutedCommands) != len(expectedCommands) {
 			t.Errorf("RestoreSession() in case %s expected %d commands, got %d",
 				testCase.Name, len(expectedCommands), len(runner.ExecutedCommands))
+			continue
 		}
 		for i, cmd := range expectedCommands {
 			if cmd != runner.ExecutedCommands[i] {
@@ -159,3 +182,96 @@ func TestRestoreSession(t *testing.T) {
 		}
 	}
 }
+
+// failingRunner records commands like MockRunner and fails at the first
+// chained command containing failOn, printing the markers tmux would print
+// up to that point.
+type failingRunner struct {
+	interfaces.MockRunner
+	failOn string
+}
+
+func (r *failingRunner) Run(cmd *exec.Cmd) error {
+	r.MockRunner.Run(cmd)
+	for group := range strings.SplitSeq(strings.Join(cmd.Args[1:], " "), " ; ") {
+		if strings.Contains(group, r.failOn) {
+			return errors.New("exit status 1")
+		}
+		if marker, ok := strings.CutPrefix(group, "display-message -p "); ok && cmd.Stdout != nil {
+			fmt.Fprintln(cmd.Stdout, marker)
+		}
+	}
+	return nil
+}
+
+func TestRestoreSessionRollback(t *testing.T) {
+	cfg := &config.Config{ProgramWhitelist: "nvim"}
+	runner := &failingRunner{failOn: "split-window"}
+	err := RestoreSession(restorationTestCases[0].Session, runner, cfg)
+	if err == nil || !strings.Contains(err.Error(), "window 2 pane 2") {
+		t.Errorf("expected an error naming window 2 pane 2, got %v", err)
+	}
+	commands := runner.ExecutedCommands
+	if last := commands[len(commands)-1]; last != "tmux kill-session -t test01" {
+		t.Errorf("expected the partial session to be killed last, got %s", last)
+	}
+	for _, cmd := range commands {
+		if strings.Contains(cmd, "switch-client") {
+			t.Errorf("expected no switch after a failed restore, got %s", cmd)
+		}
+	}
+
+	runner = &failingRunner{failOn: "new-session"}
+	if err := RestoreSession(restorationTestCases[0].Session, runner, cfg); err == nil {
+		t.Errorf("expected an error when the session cannot be created")
+	}
+	if len(runner.ExecutedCommands) != 1 {
+		t.Errorf("expected an existing session not to be touched, got %v", runner.ExecutedCommands)
+	}
+}
+
+func TestBatchArgs(t *testing.T) {
+	steps := []Step{
+		{Args: []string{"send-keys", "-t", "a:1.1", "make; make install;", "C-m"}},
+		{Args: []string{"send-keys", "-t", "a:1.1", ";", "C-m"}},
+	}
+	expected := []string{
+		"send-keys", "-t", "a:1.1", `make; make install\;`, "C-m", ";", "display-message", "-p", "0", ";",
+		"send-keys", "-t", "a:1.1", `\;`, "C-m", ";", "display-message", "-p", "1",
+	}
+	if got := BatchArgs(steps); !slices.Equal(got, expected) {
+		t.Errorf("BatchArgs() = %q, expected %q", got, expected)
+	}
+	if got := lastMarker("%1\n0\n%2\n1\n"); got != 1 {
+		t.Errorf("lastMarker() = %d, expected 1", got)
+	}
+}
+
+func TestRestoreInvocations(t *testing.T) {
+	cfg := &config.Config{ProgramWhitelist: "nvim"}
+	for _, testCase := range restorationTestCases {
+		runner := &interfaces.MockRunner{}
+		if err := RestoreSessionDetached(testCase.Session, runner, cfg); err != nil {
+			t.Fatalf("RestoreSessionDetached() in case %s error = %v", testCase.Name, err)
+		}
+		var expected []string
+		for _, invocation := range RestoreInvocations(testCase.Session, cfg) {
+			if invocation.WaitForShells {
+				t.Errorf("RestoreInvocations() in case %s expected no wait without wait-for-shell", testCase.Name)
+			}
+			expected = append(expected, "tmux "+strings.Join(invocation.Args, " "))
+		}
+		if !slices.Equal(runner.ExecutedCommands, expected) {
+			t.Errorf("RestoreInvocations() in case %s = %q, expected the executed %q", testCase.Name, expected, runner.ExecutedCommands)
+		}
+	}
+
+	cfg.WaitForShell = true
+	invocations := RestoreInvocations(restorationTestCases[0].Session, cfg)
+	for i, invocation := range invocations {
+		last := i == len(invocations)-1
+		if invocation.WaitForShells != last || last != slices.Contains(invocation.Args, "send-keys") {
+			t.Errorf("expected only the last invocation to type into panes after waiting, got %d: %+v", i, invocation)
+		}
+	}
+}
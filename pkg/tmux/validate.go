@@ -0,0 +1,77 @@
+package tmux
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/session"
+)
+
+// Problem is something a restore of a saved session cannot reproduce.
+type Problem struct {
+	Target  string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Target + ": " + p.Message
+}
+
+func dirMissing(path string) bool {
+	_, err := os.Stat(path)
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// PrepareRestore checks the directories and programs a restore relies on.
+// It fails for a stale session whose root is gone; otherwise it returns a
+// copy where missing pane directories fall back to the session root and
+// programs that are not installed are not relaunched, along with what was
+// changed.
+func PrepareRestore(s *session.Session, cfg *config.Config) (*session.Session, []Problem, error) {
+	if s.IsStale() {
+		return nil, nil, fmt.Errorf("session %s is stale: %s no longer exists (run 'go-tms prune' to remove it)",
+			s.Name, s.CurrentPath)
+	}
+
+	repaired := s.MapPaths(func(path string) string {
+		if path != "" && dirMissing(path) {
+			return s.CurrentPath
+		}
+		return path
+	})
+
+	var problems []Problem
+	for i, w := range s.Windows {
+		for j, p := range w.Panes {
+			target := fmt.Sprintf("window %d pane %d", i+1, j+1)
+			if p.CurrentPath != "" && dirMissing(p.CurrentPath) {
+				problems = append(problems, Problem{
+					Target:  target,
+					Message: fmt.Sprintf("%s does not exist, using %s", p.CurrentPath, s.CurrentPath),
+				})
+			}
+			commands := PaneCommands(session.Pane{Command: p.Command}, cfg)
+			if len(commands) == 0 {
+				continue
+			}
+			fields := strings.Fields(commands[0])
+			if len(fields) == 0 {
+				continue
+			}
+			program := fields[0]
+			if _, err := exec.LookPath(program); err != nil {
+				problems = append(problems, Problem{
+					Target:  target,
+					Message: fmt.Sprintf("%s is not installed, not relaunching it", program),
+				})
+				repaired.Windows[i].Panes[j].Command = ""
+			}
+		}
+	}
+	return &repaired, problems, nil
+}
@@ -0,0 +1,77 @@
+package tmux
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/swit33/go-tms/pkg/config"
+	"github.com/swit33/go-tms/pkg/session"
+)
+
+func TestPrepareRestore(t *testing.T) {
+	dir := t.TempDir()
+	gone := filepath.Join(dir, "gone")
+	cfg := &config.Config{ProgramWhitelist: "sh,go-tms-missing-program"}
+
+	s := &session.Session{
+		Name:        "proj",
+		CurrentPath: dir,
+		Windows: []session.Window{
+			{
+				Index: "1",
+				Panes: []session.Pane{
+					{Index: "1", CurrentPath: dir, Command: "sh"},
+					{Index: "2", CurrentPath: gone, Command: "go-tms-missing-program"},
+				},
+			},
+		},
+	}
+	restorable, problems, err := PrepareRestore(s, cfg)
+	if err != nil {
+		t.Fatalf("PrepareRestore failed: %v", err)
+	}
+	if len(problems) != 2 {
+		t.Errorf("expected 2 problems, got %v", problems)
+	}
+	panes := restorable.Windows[0].Panes
+	if panes[0].Command != "sh" || panes[0].CurrentPath != dir {
+		t.Errorf("expected the first pane to be unchanged, got %+v", panes[0])
+	}
+	if panes[1].Command != "" || panes[1].CurrentPath != dir {
+		t.Errorf("expected the second pane to fall back to the root without a program, got %+v", panes[1])
+	}
+	if s.Windows[0].Panes[1].CurrentPath != gone {
+		t.Errorf("PrepareRestore modified the original session")
+	}
+
+	s.CurrentPath = gone
+	if _, _, err := PrepareRestore(s, cfg); err == nil {
+		t.Errorf("expected an error for a stale session")
+	}
+}
+
+func TestPrepareRestoreEmptyCommand(t *testing.T) {
+	dir := t.TempDir()
+	s := &session.Session{
+		Name:        "proj",
+		CurrentPath: dir,
+		Windows: []session.Window{
+			{Index: "1", Panes: []session.Pane{{Index: "1", CurrentPath: dir}, {Index: "2", CurrentPath: dir, Command: "nvim"}}},
+		},
+	}
+	for _, cfg := range []*config.Config{
+		{ProgramWhitelist: ",btop,"},
+		{ProgramWhitelist: "nvim", NvimCustomCommand: "  "},
+	} {
+		_, problems, err := PrepareRestore(s, cfg)
+		if err != nil {
+			t.Errorf("PrepareRestore with %+v failed: %v", cfg, err)
+		}
+		if len(problems) != 0 {
+			t.Errorf("expected no problems with %+v, got %v", cfg, problems)
+		}
+		if commands := PaneCommands(s.Windows[0].Panes[0], cfg); len(commands) != 0 {
+			t.Errorf("expected a pane without a program to run nothing, got %q", commands)
+		}
+	}
+}
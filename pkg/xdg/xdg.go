@@ -0,0 +1,39 @@
+// Package xdg resolves the XDG base directories go-tms keeps its files in.
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// dir returns the directory named by env, or fallback below the home
+// directory. Relative values are ignored as the specification requires.
+func dir(env string, fallback ...string) (string, error) {
+	if value := os.Getenv(env); filepath.IsAbs(value) {
+		return value, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append([]string{homeDir}, fallback...)...), nil
+}
+
+// ConfigHome returns $XDG_CONFIG_HOME, defaulting to ~/.config.
+func ConfigHome() (string, error) {
+	return dir("XDG_CONFIG_HOME", ".config")
+}
+
+// StateHome returns $XDG_STATE_HOME, defaulting to ~/.local/state.
+func StateHome() (string, error) {
+	return dir("XDG_STATE_HOME", ".local", "state")
+}
+
+// RuntimeDir returns $XDG_RUNTIME_DIR, falling back to the state directory
+// when it is not set.
+func RuntimeDir() (string, error) {
+	if value := os.Getenv("XDG_RUNTIME_DIR"); filepath.IsAbs(value) {
+		return value, nil
+	}
+	return StateHome()
+}